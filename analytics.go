@@ -0,0 +1,197 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	htmltemplate "html/template"
+	"net/http"
+	"sort"
+	"strconv"
+
+	"github.com/sirupsen/logrus"
+)
+
+// platformDownloads is the number of downloads of the binaries for a
+// platform.
+type platformDownloads struct {
+	OS           string  `json:"os"`
+	Arch         string  `json:"arch,omitempty"`
+	Downloads    int     `json:"downloads"`
+	Repositories int     `json:"repositories"`
+	Percent      float64 `json:"percent"`
+}
+
+// assetDownloads is the number of downloads of a single binary.
+type assetDownloads struct {
+	Repository string `json:"repository"`
+	Tag        string `json:"tag"`
+	OS         string `json:"os"`
+	Arch       string `json:"arch"`
+	Asset      string `json:"asset"`
+	Downloads  int    `json:"downloads"`
+}
+
+// releaseDownloads is the number of downloads of the binaries of a
+// release.
+type releaseDownloads struct {
+	Repository string `json:"repository"`
+	Tag        string `json:"tag"`
+	Binaries   int    `json:"binaries"`
+	Downloads  int    `json:"downloads"`
+}
+
+// analytics holds the downloads of the binaries of every repository
+// broken down by platform.
+type analytics struct {
+	Total     int                 `json:"total"`
+	Platforms []platformDownloads `json:"platforms"`
+	OS        []platformDownloads `json:"os"`
+	Releases  []releaseDownloads  `json:"releases"`
+	Assets    []assetDownloads    `json:"assets"`
+}
+
+var analyticsTemplate = htmltemplate.Must(htmltemplate.New("").Parse(analyticsTmpl))
+
+// newAnalytics aggregates the downloads of the binaries of all releases.
+// Checksums and other assets are not counted.
+func newAnalytics(releases []release) *analytics {
+	a := &analytics{}
+
+	platforms := map[string]*platformDownloads{}
+	oses := map[string]*platformDownloads{}
+	platformRepos := map[string]map[string]bool{}
+	osRepos := map[string]map[string]bool{}
+	tags := map[string]*releaseDownloads{}
+
+	for _, r := range releases {
+		repo := r.Repository.GetFullName()
+		for _, ra := range r.Assets {
+			if ra.OS == "" {
+				continue
+			}
+
+			a.Total += ra.DownloadCount
+			a.Assets = append(a.Assets, assetDownloads{
+				Repository: repo,
+				Tag:        ra.Tag,
+				OS:         ra.OS,
+				Arch:       ra.Arch,
+				Asset:      ra.Name,
+				Downloads:  ra.DownloadCount,
+			})
+
+			rk := repo + "@" + ra.Tag
+			if tags[rk] == nil {
+				tags[rk] = &releaseDownloads{Repository: repo, Tag: ra.Tag}
+			}
+			tags[rk].Binaries++
+			tags[rk].Downloads += ra.DownloadCount
+
+			key := ra.OS + "/" + ra.Arch
+			if platforms[key] == nil {
+				platforms[key] = &platformDownloads{OS: ra.OS, Arch: ra.Arch}
+				platformRepos[key] = map[string]bool{}
+			}
+			platforms[key].Downloads += ra.DownloadCount
+			platformRepos[key][repo] = true
+
+			if oses[ra.OS] == nil {
+				oses[ra.OS] = &platformDownloads{OS: ra.OS}
+				osRepos[ra.OS] = map[string]bool{}
+			}
+			oses[ra.OS].Downloads += ra.DownloadCount
+			osRepos[ra.OS][repo] = true
+		}
+	}
+
+	a.Platforms = sortPlatforms(platforms, platformRepos, a.Total)
+	a.OS = sortPlatforms(oses, osRepos, a.Total)
+
+	for _, rd := range tags {
+		a.Releases = append(a.Releases, *rd)
+	}
+	sort.Slice(a.Releases, func(i, j int) bool {
+		if a.Releases[i].Downloads == a.Releases[j].Downloads {
+			return a.Releases[i].Repository+"@"+a.Releases[i].Tag < a.Releases[j].Repository+"@"+a.Releases[j].Tag
+		}
+		return a.Releases[i].Downloads > a.Releases[j].Downloads
+	})
+
+	sort.Slice(a.Assets, func(i, j int) bool {
+		if a.Assets[i].Downloads == a.Assets[j].Downloads {
+			return a.Assets[i].Asset < a.Assets[j].Asset
+		}
+		return a.Assets[i].Downloads > a.Assets[j].Downloads
+	})
+
+	return a
+}
+
+// sortPlatforms returns the platforms sorted by downloads, filling in the
+// number of repositories and their share of the total.
+func sortPlatforms(m map[string]*platformDownloads, repos map[string]map[string]bool, total int) []platformDownloads {
+	result := make([]platformDownloads, 0, len(m))
+	for key, p := range m {
+		p.Repositories = len(repos[key])
+		if total > 0 {
+			p.Percent = float64(p.Downloads) * 100 / float64(total)
+		}
+		result = append(result, *p)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Downloads == result[j].Downloads {
+			return result[i].OS+"/"+result[i].Arch < result[j].OS+"/"+result[j].Arch
+		}
+		return result[i].Downloads > result[j].Downloads
+	})
+	return result
+}
+
+// analyticsHandler serves the downloads by platform as a page at
+// /analytics, as JSON at /api/v1/analytics.json and as CSV at
+// /api/v1/analytics.csv.
+func (s *snapshot) analyticsHandler(w http.ResponseWriter, req *http.Request) {
+	s.mu.RLock()
+	a := newAnalytics(s.releases)
+	s.mu.RUnlock()
+
+	switch req.URL.Path {
+	case "/api/v1/analytics.json":
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(a)
+	case "/api/v1/analytics.csv":
+		w.Header().Set("Content-Type", "text/csv")
+		w.Write(analyticsCSV(a))
+	default:
+		b, err := renderAnalytics(a)
+		if err != nil {
+			logrus.Warnf("executing analytics template failed: %v", err)
+			http.Error(w, "executing template failed", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html")
+		w.Write(b)
+	}
+}
+
+func renderAnalytics(a *analytics) ([]byte, error) {
+	var b bytes.Buffer
+	if err := analyticsTemplate.Execute(&b, a); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// analyticsCSV returns the downloads of every binary as CSV.
+func analyticsCSV(a *analytics) []byte {
+	var b bytes.Buffer
+	cw := csv.NewWriter(&b)
+	cw.Write([]string{"repository", "tag", "os", "arch", "asset", "downloads"})
+	for _, ad := range a.Assets {
+		cw.Write([]string{ad.Repository, ad.Tag, ad.OS, ad.Arch, ad.Asset, strconv.Itoa(ad.Downloads)})
+	}
+	cw.Flush()
+	return b.Bytes()
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNewAnalytics(t *testing.T) {
+	img := testRelease("genuinetools/img")
+	img.Assets = []releaseAsset{
+		{Tag: "v2", Name: "img-linux-amd64", OS: "linux", Arch: "amd64", DownloadCount: 50},
+		{Tag: "v2", Name: "img-linux-amd64.sha256", DownloadCount: 1000},
+		{Tag: "v2", Name: "img-darwin-amd64", OS: "darwin", Arch: "amd64", DownloadCount: 10},
+		{Tag: "v1", Name: "img-linux-amd64", OS: "linux", Arch: "amd64", DownloadCount: 20},
+	}
+	reg := testRelease("genuinetools/reg")
+	reg.Assets = []releaseAsset{
+		{Tag: "v1", Name: "reg-linux-arm64", OS: "linux", Arch: "arm64", DownloadCount: 10},
+		{Tag: "v1", Name: "reg-linux-amd64", OS: "linux", Arch: "amd64", DownloadCount: 10},
+	}
+	// Repositories without binaries are left out.
+	empty := testRelease("genuinetools/empty")
+
+	want := &analytics{
+		Total: 100,
+		Platforms: []platformDownloads{
+			{OS: "linux", Arch: "amd64", Downloads: 80, Repositories: 2, Percent: 80},
+			{OS: "darwin", Arch: "amd64", Downloads: 10, Repositories: 1, Percent: 10},
+			{OS: "linux", Arch: "arm64", Downloads: 10, Repositories: 1, Percent: 10},
+		},
+		OS: []platformDownloads{
+			{OS: "linux", Downloads: 90, Repositories: 2, Percent: 90},
+			{OS: "darwin", Downloads: 10, Repositories: 1, Percent: 10},
+		},
+		Releases: []releaseDownloads{
+			{Repository: "genuinetools/img", Tag: "v2", Binaries: 2, Downloads: 60},
+			{Repository: "genuinetools/img", Tag: "v1", Binaries: 1, Downloads: 20},
+			{Repository: "genuinetools/reg", Tag: "v1", Binaries: 2, Downloads: 20},
+		},
+		Assets: []assetDownloads{
+			{Repository: "genuinetools/img", Tag: "v2", OS: "linux", Arch: "amd64", Asset: "img-linux-amd64", Downloads: 50},
+			{Repository: "genuinetools/img", Tag: "v1", OS: "linux", Arch: "amd64", Asset: "img-linux-amd64", Downloads: 20},
+			{Repository: "genuinetools/img", Tag: "v2", OS: "darwin", Arch: "amd64", Asset: "img-darwin-amd64", Downloads: 10},
+			{Repository: "genuinetools/reg", Tag: "v1", OS: "linux", Arch: "amd64", Asset: "reg-linux-amd64", Downloads: 10},
+			{Repository: "genuinetools/reg", Tag: "v1", OS: "linux", Arch: "arm64", Asset: "reg-linux-arm64", Downloads: 10},
+		},
+	}
+
+	got := newAnalytics([]release{img, reg, empty})
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got\n%+v\nwant\n%+v", got, want)
+	}
+
+	wantCSV := `repository,tag,os,arch,asset,downloads
+genuinetools/img,v2,linux,amd64,img-linux-amd64,50
+genuinetools/img,v1,linux,amd64,img-linux-amd64,20
+genuinetools/img,v2,darwin,amd64,img-darwin-amd64,10
+genuinetools/reg,v1,linux,amd64,reg-linux-amd64,10
+genuinetools/reg,v1,linux,arm64,reg-linux-arm64,10
+`
+	if csv := string(analyticsCSV(got)); csv != wantCSV {
+		t.Errorf("got CSV\n%s\nwant\n%s", csv, wantCSV)
+	}
+
+	// Without any downloads there are no percentages to divide by zero.
+	none := newAnalytics([]release{empty})
+	if none.Total != 0 || len(none.Platforms) != 0 || len(none.Releases) != 0 {
+		t.Errorf("got %+v for no binaries, want an empty breakdown", none)
+	}
+}
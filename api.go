@@ -0,0 +1,91 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+// apiRelease is the JSON representation of the latest release of a
+// repository.
+type apiRelease struct {
+	Repository  string                         `json:"repository"`
+	Description string                         `json:"description,omitempty"`
+	URL         string                         `json:"url"`
+	Tag         string                         `json:"tag"`
+	Name        string                         `json:"name,omitempty"`
+	ReleaseURL  string                         `json:"release_url"`
+	PublishedAt time.Time                      `json:"published_at"`
+	Downloads   int                            `json:"downloads"`
+	Platforms   map[string]map[string]apiAsset `json:"platforms"`
+	Warnings    []lintWarning                  `json:"warnings,omitempty"`
+}
+
+// apiAsset is the JSON representation of a binary.
+type apiAsset struct {
+	Name   string `json:"name"`
+	URL    string `json:"url"`
+	SHA256 string `json:"sha256,omitempty"`
+}
+
+func newAPIRelease(r release) apiRelease {
+	ar := apiRelease{
+		Repository:  r.Repository.GetFullName(),
+		Description: r.Repository.GetDescription(),
+		URL:         r.Repository.GetHTMLURL(),
+		Tag:         r.Release.GetTagName(),
+		Name:        r.Release.GetName(),
+		ReleaseURL:  r.Release.GetHTMLURL(),
+		PublishedAt: r.Release.GetPublishedAt().Time,
+		Downloads:   r.BinaryDownloadCount,
+		Platforms:   map[string]map[string]apiAsset{},
+		Warnings:    r.Warnings,
+	}
+	for osn, v := range r.Platforms {
+		for arch, b := range v {
+			if b.BinaryURL == "" {
+				continue
+			}
+			if ar.Platforms[osn] == nil {
+				ar.Platforms[osn] = map[string]apiAsset{}
+			}
+			ar.Platforms[osn][arch] = apiAsset{
+				Name:   b.BinaryName,
+				URL:    b.BinaryURL,
+				SHA256: b.BinarySHA256,
+			}
+		}
+	}
+	return ar
+}
+
+// releasesJSON returns the latest release of every repository as JSON.
+func releasesJSON(releases []release) ([]byte, error) {
+	result := make([]apiRelease, 0, len(releases))
+	for _, r := range releases {
+		if r.Release == nil {
+			continue
+		}
+		result = append(result, newAPIRelease(r))
+	}
+	return json.MarshalIndent(result, "", "  ")
+}
+
+// releaseJSON returns the latest release of a repository as JSON.
+func releaseJSON(r release) ([]byte, error) {
+	return json.MarshalIndent(newAPIRelease(r), "", "  ")
+}
+
+// releasesHandler serves the latest release of every repository as JSON.
+func (s *snapshot) releasesHandler(w http.ResponseWriter, req *http.Request) {
+	s.mu.RLock()
+	b, err := releasesJSON(s.releases)
+	s.mu.RUnlock()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(b)
+}
@@ -0,0 +1,241 @@
+package main
+
+import (
+	"context"
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/google/go-github/github"
+	"golang.org/x/oauth2"
+)
+
+const (
+	authToken     = "token"
+	authApp       = "app"
+	authAnonymous = "anonymous"
+
+	// appJWTLifetime is how long the JWT of the GitHub App is valid, GitHub
+	// allows at most 10 minutes.
+	appJWTLifetime = 9 * time.Minute
+	// appTokenEarly is how long before they expire the installation tokens
+	// are refreshed.
+	appTokenEarly = 5 * time.Minute
+)
+
+var (
+	// appID, appKeyFile and appInstallationID authenticate as a GitHub
+	// App installation.
+	appID             int64
+	appKeyFile        string
+	appInstallationID int64
+
+	// anonymous is set to use the GitHub API without authentication, it is
+	// read-only and has a much lower rate limit.
+	anonymous bool
+)
+
+// authMode returns how we authenticate to GitHub.
+func authMode() string {
+	return currentConfig().authMode()
+}
+
+// authMode returns how the config authenticates to GitHub.
+func (c savedConfig) authMode() string {
+	switch {
+	case c.appID != 0:
+		return authApp
+	case token != "":
+		return authToken
+	case c.anonymous:
+		return authAnonymous
+	}
+	return ""
+}
+
+// validateAuth checks the authentication flags.
+func validateAuth() error {
+	if (appID != 0) != (appKeyFile != "") {
+		return errors.New("--app-id and --app-key must be set together")
+	}
+	if appID != 0 && token != "" {
+		return errors.New("--app-id cannot be combined with --token or GITHUB_TOKEN")
+	}
+	if appID != 0 && anonymous {
+		return errors.New("--anonymous cannot be combined with --app-id")
+	}
+	if token != "" && anonymous {
+		return errors.New("--anonymous cannot be combined with --token or GITHUB_TOKEN")
+	}
+	if anonymous && updateReleaseBody == updateModeOn {
+		return errors.New("updating release bodies needs a token or a GitHub App, not --anonymous")
+	}
+	return nil
+}
+
+// newAppTokenSource returns a token source for the installation tokens of
+// the GitHub App of the config, they are refreshed before they expire.
+func newAppTokenSource(ctx context.Context, c savedConfig, base http.RoundTripper, baseURL *url.URL) (oauth2.TokenSource, error) {
+	b, err := ioutil.ReadFile(c.appKeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("reading the GitHub App private key failed: %v", err)
+	}
+	key, err := parseRSAPrivateKey(b)
+	if err != nil {
+		return nil, fmt.Errorf("parsing the GitHub App private key %s failed: %v", c.appKeyFile, err)
+	}
+
+	// The installation tokens are requested as the app itself.
+	app := github.NewClient(&http.Client{
+		Transport: &appTransport{base: base, id: c.appID, key: key},
+	})
+	app.BaseURL = baseURL
+
+	s := &appTokenSource{app: app, installationID: c.appInstallationID}
+	if s.installationID == 0 {
+		if s.installationID, err = findInstallation(ctx, app, c.orgs); err != nil {
+			return nil, err
+		}
+	}
+
+	// Get the first token now, so a wrong app id, key or installation is
+	// reported right away.
+	t, err := s.Token()
+	if err != nil {
+		return nil, err
+	}
+	return oauth2.ReuseTokenSource(t, s), nil
+}
+
+// findInstallation returns the installation of the app on the first of the
+// orgs, which can be an org or a user, or the only installation of the app.
+func findInstallation(ctx context.Context, app *github.Client, orgs []string) (int64, error) {
+	if len(orgs) > 0 {
+		i, resp, err := app.Apps.FindOrganizationInstallation(ctx, orgs[0])
+		if resp != nil && resp.StatusCode == http.StatusNotFound {
+			i, _, err = app.Apps.FindUserInstallation(ctx, orgs[0])
+		}
+		if err != nil {
+			return 0, fmt.Errorf("finding the GitHub App installation on %s failed: %v", orgs[0], err)
+		}
+		return i.GetID(), nil
+	}
+
+	installations, _, err := app.Apps.ListInstallations(ctx, &github.ListOptions{PerPage: 100})
+	if err != nil {
+		return 0, fmt.Errorf("listing the GitHub App installations failed: %v", err)
+	}
+	if len(installations) != 1 {
+		return 0, fmt.Errorf("the GitHub App has %d installations, pass --app-installation-id or --orgs", len(installations))
+	}
+	return installations[0].GetID(), nil
+}
+
+// appTokenSource creates installation tokens of a GitHub App.
+type appTokenSource struct {
+	app            *github.Client
+	installationID int64
+}
+
+func (s *appTokenSource) Token() (*oauth2.Token, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	t, _, err := s.app.Apps.CreateInstallationToken(ctx, s.installationID)
+	if err != nil {
+		return nil, fmt.Errorf("creating a token for GitHub App installation %d failed: %v", s.installationID, err)
+	}
+	expiry := t.GetExpiresAt()
+	if expiry.IsZero() {
+		// Installation tokens are valid for an hour.
+		expiry = time.Now().Add(time.Hour)
+	}
+	return &oauth2.Token{
+		AccessToken: t.GetToken(),
+		TokenType:   "token",
+		// Refresh it early, so a request does not fail because the token
+		// expired while it was in flight.
+		Expiry: expiry.Add(-appTokenEarly),
+	}, nil
+}
+
+// appTransport authenticates requests as the GitHub App with a JWT.
+type appTransport struct {
+	base http.RoundTripper
+	id   int64
+	key  *rsa.PrivateKey
+}
+
+func (t *appTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	jwt, err := appJWT(t.id, t.key, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	// Do not modify the request of the caller.
+	r := new(http.Request)
+	*r = *req
+	r.Header = make(http.Header, len(req.Header))
+	for k, v := range req.Header {
+		r.Header[k] = v
+	}
+	r.Header.Set("Authorization", "Bearer "+jwt)
+	return t.base.RoundTrip(r)
+}
+
+// appJWT returns a JWT signed with RS256 that authenticates as the app.
+func appJWT(id int64, key *rsa.PrivateKey, now time.Time) (string, error) {
+	header, err := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT"})
+	if err != nil {
+		return "", err
+	}
+	claims, err := json.Marshal(map[string]interface{}{
+		// Backdate it a little against clock drift.
+		"iat": now.Add(-time.Minute).Unix(),
+		"exp": now.Add(appJWTLifetime).Unix(),
+		"iss": id,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	enc := base64.RawURLEncoding
+	unsigned := enc.EncodeToString(header) + "." + enc.EncodeToString(claims)
+	sum := sha256.Sum256([]byte(unsigned))
+	sig, err := rsa.SignPKCS1v15(nil, key, crypto.SHA256, sum[:])
+	if err != nil {
+		return "", err
+	}
+	return unsigned + "." + enc.EncodeToString(sig), nil
+}
+
+// parseRSAPrivateKey parses a PEM encoded PKCS #1 or PKCS #8 RSA private
+// key, like the ones GitHub generates for apps.
+func parseRSAPrivateKey(b []byte) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode(b)
+	if block == nil {
+		return nil, errors.New("no PEM data found")
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	rsaKey, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("not an RSA private key")
+	}
+	return rsaKey, nil
+}
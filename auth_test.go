@@ -0,0 +1,235 @@
+package main
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/github"
+)
+
+func TestAppJWT(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Unix(1600000000, 0)
+
+	jwt, err := appJWT(42, key, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parts := strings.Split(jwt, ".")
+	if len(parts) != 3 {
+		t.Fatalf("got %d parts, want 3", len(parts))
+	}
+
+	enc := base64.RawURLEncoding
+	var header map[string]string
+	b, err := enc.DecodeString(parts[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(b, &header); err != nil {
+		t.Fatal(err)
+	}
+	if header["alg"] != "RS256" || header["typ"] != "JWT" {
+		t.Errorf("got header %v, want alg RS256 and typ JWT", header)
+	}
+
+	var claims map[string]int64
+	if b, err = enc.DecodeString(parts[1]); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(b, &claims); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]int64{
+		"iss": 42,
+		"iat": now.Add(-time.Minute).Unix(),
+		"exp": now.Add(appJWTLifetime).Unix(),
+	}
+	for k, v := range want {
+		if claims[k] != v {
+			t.Errorf("got claim %s %d, want %d", k, claims[k], v)
+		}
+	}
+
+	sig, err := enc.DecodeString(parts[2])
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if err := rsa.VerifyPKCS1v15(&key.PublicKey, crypto.SHA256, sum[:], sig); err != nil {
+		t.Errorf("verifying the signature failed: %v", err)
+	}
+}
+
+func TestParseRSAPrivateKey(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkcs8, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecPKCS8, err := x509.MarshalPKCS8PrivateKey(ecKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		name string
+		pem  []byte
+		err  string
+	}{
+		{
+			name: "pkcs1",
+			pem:  pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}),
+		},
+		{
+			name: "pkcs8",
+			pem:  pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: pkcs8}),
+		},
+		{
+			name: "not pem",
+			pem:  []byte("not a key"),
+			err:  "no PEM data found",
+		},
+		{
+			name: "not a key",
+			pem:  pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: []byte("garbage")}),
+			err:  "asn1",
+		},
+		{
+			name: "ec key",
+			pem:  pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: ecPKCS8}),
+			err:  "not an RSA private key",
+		},
+	}
+
+	for _, tc := range testCases {
+		got, err := parseRSAPrivateKey(tc.pem)
+		if tc.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tc.err) {
+				t.Errorf("%s: got error %v, want it to contain %q", tc.name, err, tc.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tc.name, err)
+			continue
+		}
+		if !got.Equal(key) {
+			t.Errorf("%s: got a different key", tc.name)
+		}
+	}
+}
+
+func TestValidateAuth(t *testing.T) {
+	prevAppID, prevKey, prevToken, prevAnonymous, prevUpdate := appID, appKeyFile, token, anonymous, updateReleaseBody
+	t.Cleanup(func() {
+		appID, appKeyFile, token, anonymous, updateReleaseBody = prevAppID, prevKey, prevToken, prevAnonymous, prevUpdate
+	})
+
+	testCases := []struct {
+		name      string
+		appID     int64
+		key       string
+		token     string
+		anonymous bool
+		update    updateMode
+		err       string
+	}{
+		{name: "token", token: "x", update: updateModeOn},
+		{name: "app", appID: 1, key: "app.pem", update: updateModeOn},
+		{name: "anonymous", anonymous: true, update: updateModeDryRun},
+		{name: "app without key", appID: 1, err: "--app-id and --app-key must be set together"},
+		{name: "key without app", key: "app.pem", err: "--app-id and --app-key must be set together"},
+		{name: "app and token", appID: 1, key: "app.pem", token: "x", err: "--app-id cannot be combined with --token"},
+		{name: "app and anonymous", appID: 1, key: "app.pem", anonymous: true, err: "--anonymous cannot be combined with --app-id"},
+		{name: "token and anonymous", token: "x", anonymous: true, err: "--anonymous cannot be combined with --token"},
+		{name: "anonymous updates", anonymous: true, update: updateModeOn, err: "needs a token or a GitHub App"},
+	}
+
+	for _, tc := range testCases {
+		appID, appKeyFile, token, anonymous, updateReleaseBody = tc.appID, tc.key, tc.token, tc.anonymous, tc.update
+		err := validateAuth()
+		if tc.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tc.err) {
+				t.Errorf("%s: got error %v, want it to contain %q", tc.name, err, tc.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tc.name, err)
+		}
+	}
+}
+
+func TestFindInstallation(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/orgs/genuinetools/installation", func(w http.ResponseWriter, req *http.Request) {
+		fmt.Fprint(w, `{"id": 1}`)
+	})
+	mux.HandleFunc("/users/jessfraz/installation", func(w http.ResponseWriter, req *http.Request) {
+		fmt.Fprint(w, `{"id": 2}`)
+	})
+	mux.HandleFunc("/app/installations", func(w http.ResponseWriter, req *http.Request) {
+		fmt.Fprint(w, `[{"id": 3}]`)
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	app := github.NewClient(nil)
+	app.BaseURL, _ = url.Parse(srv.URL + "/")
+
+	testCases := []struct {
+		name string
+		orgs []string
+		want int64
+		err  string
+	}{
+		{name: "org", orgs: []string{"genuinetools", "jessfraz"}, want: 1},
+		{name: "user", orgs: []string{"jessfraz", "genuinetools"}, want: 2},
+		{name: "only installation", want: 3},
+		{name: "not installed", orgs: []string{"other"}, err: "finding the GitHub App installation on other failed"},
+	}
+
+	for _, tc := range testCases {
+		got, err := findInstallation(context.Background(), app, tc.orgs)
+		if tc.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tc.err) {
+				t.Errorf("%s: got error %v, want it to contain %q", tc.name, err, tc.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tc.name, err)
+			continue
+		}
+		if got != tc.want {
+			t.Errorf("%s: got installation %d, want %d", tc.name, got, tc.want)
+		}
+	}
+}
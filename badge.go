@@ -0,0 +1,48 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"strconv"
+)
+
+const badgeTmpl = `<svg xmlns="http://www.w3.org/2000/svg" width="%[1]d" height="20" role="img" aria-label="%[3]s: %[4]s">
+<title>%[3]s: %[4]s</title>
+<rect width="%[2]d" height="20" fill="#555"/>
+<rect x="%[2]d" width="%[5]d" height="20" fill="%[6]s"/>
+<g fill="#fff" text-anchor="middle" font-family="Verdana,Geneva,DejaVu Sans,sans-serif" font-size="11">
+<text x="%[7]d" y="14">%[3]s</text>
+<text x="%[8]d" y="14">%[4]s</text>
+</g>
+</svg>
+`
+
+// badge renders a flat badge like the ones from shields.io.
+func badge(label, value, color string) []byte {
+	// Estimate the text width, there is no font metrics in the standard
+	// library.
+	lw := 7*len(label) + 10
+	vw := 7*len(value) + 10
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, badgeTmpl, lw+vw, lw, html.EscapeString(label), html.EscapeString(value), vw, color, lw/2, lw+vw/2)
+	return b.Bytes()
+}
+
+// releaseBadge returns a badge with the latest release of a repository.
+func releaseBadge(r release) ([]byte, error) {
+	color := "#007ec6"
+	if len(r.Warnings) > 0 {
+		color = "#dfb317"
+	}
+	if r.Release == nil {
+		return badge("release", "none", "#9f9f9f"), nil
+	}
+	return badge("release", r.Release.GetTagName(), color), nil
+}
+
+// downloadsBadge returns a badge with the download count of a repository.
+func downloadsBadge(r release) ([]byte, error) {
+	return badge("downloads", strconv.Itoa(r.BinaryDownloadCount), "#4c1"), nil
+}
@@ -0,0 +1,383 @@
+package main
+
+import (
+	"container/list"
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	units "github.com/docker/go-units"
+	"github.com/peterbourgon/diskv"
+)
+
+const (
+	cacheDisk   = "disk"
+	cacheMemory = "memory"
+	cacheNone   = "none"
+)
+
+var (
+	// cacheBackend is where the GitHub API responses are cached.
+	cacheBackend string
+	// cacheDir is where the GitHub API responses are cached on disk.
+	cacheDir string
+	// cacheMaxSize is the size of the cache over which the least recently
+	// used responses are evicted, 0 for no limit.
+	cacheMaxSize = byteSize(512 * units.MiB)
+
+	// httpCache is shared by all clients, so rebuilding the client on a
+	// reload keeps the cache. It is nil if caching is disabled.
+	httpCache *lruCache
+)
+
+// byteSize is a size flag like 100MB.
+type byteSize int64
+
+func (b *byteSize) String() string {
+	return units.BytesSize(float64(*b))
+}
+
+func (b *byteSize) Set(value string) error {
+	n, err := units.RAMInBytes(value)
+	if err != nil {
+		return err
+	}
+	if n < 0 {
+		return fmt.Errorf("size cannot be negative")
+	}
+	*b = byteSize(n)
+	return nil
+}
+
+// validateCache checks the cache flags.
+func validateCache() error {
+	switch cacheBackend {
+	case cacheDisk, cacheMemory, cacheNone:
+		return nil
+	}
+	return fmt.Errorf("--cache must be %s, %s or %s, got %q", cacheMemory, cacheDisk, cacheNone, cacheBackend)
+}
+
+// getHTTPCache returns the cache for the GitHub API responses, it is
+// created the first time.
+func getHTTPCache() (*lruCache, error) {
+	if httpCache != nil || cacheBackend == cacheNone {
+		return httpCache, nil
+	}
+	c, err := newLRUCache(cacheBackend, cacheDir, int64(cacheMaxSize))
+	if err != nil {
+		return nil, err
+	}
+	httpCache = c
+	return c, nil
+}
+
+// lruCache is an httpcache.Cache in memory or on disk that evicts the least
+// recently used responses once it is larger than its max size.
+type lruCache struct {
+	mu sync.Mutex
+
+	// d stores the responses on disk, they are kept in the entries if it
+	// is nil.
+	d   *diskv.Diskv
+	dir string
+	max int64
+
+	size      int64
+	evictions int
+	// order holds the entries, the most recently used first.
+	order   *list.List
+	entries map[string]*list.Element
+}
+
+type cacheEntry struct {
+	key  string
+	size int64
+	used time.Time
+	data []byte
+}
+
+// cacheStats are the statistics of the HTTP cache.
+type cacheStats struct {
+	Backend   string `json:"backend"`
+	Dir       string `json:"dir,omitempty"`
+	Entries   int    `json:"entries"`
+	Size      int64  `json:"size_bytes"`
+	MaxSize   int64  `json:"max_size_bytes"`
+	Evictions int    `json:"evictions"`
+}
+
+// newLRUCache returns a cache in memory or on disk in dir. The responses
+// already in dir are kept, the least recently modified are evicted first.
+func newLRUCache(backend, dir string, max int64) (*lruCache, error) {
+	c := &lruCache{
+		dir:     dir,
+		max:     max,
+		order:   list.New(),
+		entries: map[string]*list.Element{},
+	}
+	if backend != cacheDisk {
+		return c, nil
+	}
+
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	// The responses can be of private repositories, so do not leave them
+	// readable by others in a directory that already existed.
+	fi, err := os.Stat(dir)
+	if err != nil {
+		return nil, err
+	}
+	if fi.Mode().Perm()&0077 != 0 {
+		if err := os.Chmod(dir, 0700); err != nil {
+			return nil, err
+		}
+	}
+	c.d = diskv.New(diskv.Options{
+		BasePath: dir,
+		// Write to a temporary file first, so a crash does not leave a
+		// partial response behind.
+		TempDir:  filepath.Join(dir, ".tmp"),
+		PathPerm: 0700,
+		FilePerm: 0600,
+	})
+
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].ModTime().Before(files[j].ModTime())
+	})
+	for _, fi := range files {
+		if fi.IsDir() {
+			continue
+		}
+		c.entries[fi.Name()] = c.order.PushFront(&cacheEntry{
+			key:  fi.Name(),
+			size: fi.Size(),
+			used: fi.ModTime(),
+		})
+		c.size += fi.Size()
+	}
+	c.evict()
+	return c, nil
+}
+
+// cacheKey hashes the key the same way as diskcache, so a cache directory
+// written by it can be reused.
+func cacheKey(key string) string {
+	h := md5.New()
+	io.WriteString(h, key)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Get returns the response for key if it is cached.
+func (c *lruCache) Get(key string) ([]byte, bool) {
+	key = cacheKey(key)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	e := el.Value.(*cacheEntry)
+	data := e.data
+	if c.d != nil {
+		var err error
+		if data, err = c.d.Read(key); err != nil {
+			c.remove(el)
+			return nil, false
+		}
+		// The modification time is the last use, so the order survives
+		// a restart.
+		now := time.Now()
+		os.Chtimes(filepath.Join(c.dir, key), now, now)
+	}
+	e.used = time.Now()
+	c.order.MoveToFront(el)
+	return data, true
+}
+
+// Set caches the response for key.
+func (c *lruCache) Set(key string, data []byte) {
+	key = cacheKey(key)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.entries[key]; ok {
+		c.remove(el)
+	}
+	if c.max > 0 && int64(len(data)) > c.max {
+		return
+	}
+
+	e := &cacheEntry{
+		key:  key,
+		size: int64(len(data)),
+		used: time.Now(),
+	}
+	if c.d != nil {
+		if err := c.d.Write(key, data); err != nil {
+			return
+		}
+	} else {
+		e.data = data
+	}
+	c.entries[key] = c.order.PushFront(e)
+	c.size += e.size
+	c.evict()
+}
+
+// Delete removes the response for key.
+func (c *lruCache) Delete(key string) {
+	key = cacheKey(key)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.entries[key]; ok {
+		c.remove(el)
+	}
+}
+
+// evict removes the least recently used responses until the cache fits in
+// its max size.
+func (c *lruCache) evict() {
+	for c.max > 0 && c.size > c.max {
+		c.remove(c.order.Back())
+		c.evictions++
+		metricCacheEvictions.inc()
+	}
+}
+
+func (c *lruCache) remove(el *list.Element) {
+	e := el.Value.(*cacheEntry)
+	if c.d != nil {
+		c.d.Erase(e.key)
+	}
+	c.order.Remove(el)
+	delete(c.entries, e.key)
+	c.size -= e.size
+}
+
+// prune evicts the responses that were not used for longer than olderThan,
+// if it is not 0, and the least recently used ones over the max size. It
+// returns how many responses were removed and their size.
+func (c *lruCache) prune(olderThan time.Duration) (int, int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entries, size := len(c.entries), c.size
+	if olderThan > 0 {
+		for el := c.order.Back(); el != nil; el = c.order.Back() {
+			if time.Since(el.Value.(*cacheEntry).used) <= olderThan {
+				break
+			}
+			c.remove(el)
+		}
+	}
+	c.evict()
+	return entries - len(c.entries), size - c.size
+}
+
+// clear removes all responses and returns how many there were and their
+// size.
+func (c *lruCache) clear() (int, int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entries, size := len(c.entries), c.size
+	for el := c.order.Back(); el != nil; el = c.order.Back() {
+		c.remove(el)
+	}
+	return entries, size
+}
+
+func (c *lruCache) stats() cacheStats {
+	if c == nil {
+		return cacheStats{Backend: cacheNone}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	s := cacheStats{
+		Backend:   cacheMemory,
+		Entries:   len(c.entries),
+		Size:      c.size,
+		MaxSize:   c.max,
+		Evictions: c.evictions,
+	}
+	if c.d != nil {
+		s.Backend = cacheDisk
+		s.Dir = c.dir
+	}
+	return s
+}
+
+const cacheHelp = `Prune or clear the HTTP cache in --cache-dir.`
+
+func (cmd *cacheCommand) Name() string      { return "cache" }
+func (cmd *cacheCommand) Args() string      { return "prune|clear" }
+func (cmd *cacheCommand) ShortHelp() string { return cacheHelp }
+func (cmd *cacheCommand) LongHelp() string {
+	return cacheHelp + `
+
+prune removes the least recently used responses until the cache fits in
+--cache-max-size, and those not used for longer than --older-than. clear
+removes all responses.`
+}
+func (cmd *cacheCommand) Hidden() bool { return false }
+
+func (cmd *cacheCommand) Register(fs *flag.FlagSet) {
+	fs.DurationVar(&cmd.olderThan, "older-than", 0, "also prune the responses not used for longer than this (0 to disable)")
+}
+
+type cacheCommand struct {
+	olderThan time.Duration
+}
+
+func (cmd *cacheCommand) Run(ctx context.Context, args []string) error {
+	if len(args) != 1 || (args[0] != "prune" && args[0] != "clear") {
+		return errors.New("pass prune or clear")
+	}
+
+	if _, err := os.Stat(cacheDir); err != nil {
+		return err
+	}
+	c, err := newLRUCache(cacheDisk, cacheDir, 0)
+	if err != nil {
+		return err
+	}
+	c.max = int64(cacheMaxSize)
+
+	var (
+		n    int
+		size int64
+	)
+	if args[0] == "clear" {
+		n, size = c.clear()
+	} else {
+		n, size = c.prune(cmd.olderThan)
+	}
+
+	s := c.stats()
+	fmt.Printf("Removed %d responses (%s) from %s, %d responses (%s) are left.\n",
+		n, units.BytesSize(float64(size)), cacheDir, s.Entries, units.BytesSize(float64(s.Size)))
+	return nil
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLRUCache(t *testing.T) {
+	for _, backend := range []string{cacheMemory, cacheDisk} {
+		c, err := newLRUCache(backend, filepath.Join(t.TempDir(), "cache"), 10)
+		if err != nil {
+			t.Fatalf("%s: %v", backend, err)
+		}
+
+		// Each step is an operation and the keys expected in the cache
+		// after it, least recently used first.
+		steps := []struct {
+			name string
+			op   func()
+			want string
+		}{
+			{"set a", func() { c.Set("a", []byte("aaa")) }, "a"},
+			{"set b", func() { c.Set("b", []byte("bbb")) }, "a b"},
+			{"set c", func() { c.Set("c", []byte("ccc")) }, "a b c"},
+			{"get a", func() { c.Get("a") }, "b c a"},
+			{"set d evicts b", func() { c.Set("d", []byte("ddd")) }, "c a d"},
+			{"replace c", func() { c.Set("c", []byte("c")) }, "a d c"},
+			{"too large", func() { c.Set("e", []byte("eeeeeeeeeee")) }, "a d c"},
+			{"delete d", func() { c.Delete("d") }, "a c"},
+			{"set f", func() { c.Set("f", []byte("ffffff")) }, "a c f"},
+		}
+
+		for _, step := range steps {
+			step.op()
+
+			var got []string
+			for el := c.order.Back(); el != nil; el = el.Prev() {
+				for _, k := range []string{"a", "b", "c", "d", "e", "f"} {
+					if el.Value.(*cacheEntry).key == cacheKey(k) {
+						got = append(got, k)
+					}
+				}
+			}
+			if strings.Join(got, " ") != step.want {
+				t.Errorf("%s: %s: got %q, want %q", backend, step.name, strings.Join(got, " "), step.want)
+			}
+			if s := c.stats(); s.Size > 10 {
+				t.Errorf("%s: %s: got size %d, want at most 10", backend, step.name, s.Size)
+			}
+		}
+
+		if data, ok := c.Get("c"); !ok || string(data) != "c" {
+			t.Errorf("%s: got %q, %v for c, want \"c\", true", backend, data, ok)
+		}
+		if _, ok := c.Get("b"); ok {
+			t.Errorf("%s: b should have been evicted", backend)
+		}
+		if s := c.stats(); s.Entries != 3 || s.Size != 10 || s.Evictions != 1 {
+			t.Errorf("%s: got %d entries of %d bytes and %d evictions, want 3, 10 and 1", backend, s.Entries, s.Size, s.Evictions)
+		}
+
+		if n, size := c.clear(); n != 3 || size != 10 {
+			t.Errorf("%s: clear removed %d entries of %d bytes, want 3 and 10", backend, n, size)
+		}
+		if _, ok := c.Get("a"); ok {
+			t.Errorf("%s: a should have been cleared", backend)
+		}
+	}
+}
+
+func TestLRUCacheDiskReopen(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "cache")
+	c, err := newLRUCache(cacheDisk, dir, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Set("a", []byte("aaa"))
+	c.Set("b", []byte("bbb"))
+	c.Set("c", []byte("ccc"))
+
+	// The order is kept in the modification times.
+	old := time.Now().Add(-time.Hour)
+	for i, k := range []string{"b", "a", "c"} {
+		used := old.Add(time.Duration(i) * time.Minute)
+		if err := os.Chtimes(filepath.Join(dir, cacheKey(k)), used, used); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Reopening with a smaller max size evicts the least recently used.
+	c, err = newLRUCache(cacheDisk, dir, 6)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := c.Get("b"); ok {
+		t.Error("b should have been evicted on reopen")
+	}
+
+	// Only the entries unused for longer than olderThan are pruned, c is
+	// used now.
+	if data, ok := c.Get("c"); !ok || string(data) != "ccc" {
+		t.Errorf("got %q, %v for c after reopen", data, ok)
+	}
+	if n, size := c.prune(30 * time.Minute); n != 1 || size != 3 {
+		t.Errorf("prune removed %d entries of %d bytes, want 1 and 3", n, size)
+	}
+	if _, ok := c.Get("a"); ok {
+		t.Error("a should have been pruned")
+	}
+	if _, ok := c.Get("c"); !ok {
+		t.Error("c should not have been pruned")
+	}
+}
+
+func TestLRUCacheDiskMode(t *testing.T) {
+	testCases := []struct {
+		name string
+		mode os.FileMode
+		want os.FileMode
+	}{
+		{"private", 0700, 0700},
+		{"readable by others", 0755, 0700},
+		{"writable by the group", 0770, 0700},
+	}
+
+	for _, tc := range testCases {
+		dir := filepath.Join(t.TempDir(), "cache")
+		if err := os.Mkdir(dir, tc.mode); err != nil {
+			t.Fatal(err)
+		}
+		// The umask may have masked the mode.
+		if err := os.Chmod(dir, tc.mode); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := newLRUCache(cacheDisk, dir, 0); err != nil {
+			t.Fatal(err)
+		}
+		fi, err := os.Stat(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := fi.Mode().Perm(); got != tc.want {
+			t.Errorf("%s: got mode %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,295 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/google/go-github/github"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	changelogConventional = "conventional"
+	changelogLabels       = "labels"
+
+	changelogOther = "Other Changes"
+
+	// changelogPullRequestPages is how many pages of the most recently
+	// updated closed pull requests are searched for the pull requests of a
+	// changelog. Older ones are listed without their labels.
+	changelogPullRequestPages = 3
+)
+
+var (
+	// conventionalCommitRegexp matches the subject of a conventional commit
+	// like "feat(scope)!: description".
+	conventionalCommitRegexp = regexp.MustCompile(`^(\w+)(\([^)]*\))?(!)?:\s*(.+)$`)
+	// mergeCommitRegexp matches the subject of a GitHub merge commit.
+	mergeCommitRegexp = regexp.MustCompile(`^Merge pull request #(\d+) from \S+`)
+	// squashCommitRegexp matches the subject of a GitHub squash merge.
+	squashCommitRegexp = regexp.MustCompile(`\(#(\d+)\)$`)
+
+	// conventionalTypes maps conventional commit types to their group
+	// titles, in the order the groups are rendered.
+	conventionalTypes = []struct {
+		Type  string
+		Title string
+	}{
+		{"feat", "Features"},
+		{"fix", "Bug Fixes"},
+		{"perf", "Performance Improvements"},
+		{"refactor", "Refactoring"},
+		{"docs", "Documentation"},
+		{"test", "Tests"},
+		{"build", "Build System"},
+		{"ci", "Continuous Integration"},
+		{"chore", "Chores"},
+	}
+
+	// changelogs caches the changelogs by repository and tags, since the
+	// commits between two tags do not change.
+	changelogs   = map[string]*changelog{}
+	changelogsMu sync.Mutex
+)
+
+// changelog holds the changes between two releases.
+type changelog struct {
+	Base       string
+	Head       string
+	CompareURL string
+	Groups     []changelogGroup
+}
+
+type changelogGroup struct {
+	Title   string
+	Entries []changelogEntry
+}
+
+type changelogEntry struct {
+	Title       string
+	SHA         string
+	URL         string
+	Author      string
+	PullRequest int
+	Labels      []string
+	Breaking    bool
+}
+
+// previousRelease returns the release before the i-th newest release, or
+// nil if there is none. Drafts are skipped since their tags may not exist.
+func previousRelease(releases []*github.RepositoryRelease, i int) *github.RepositoryRelease {
+	for _, r := range releases[i+1:] {
+		if !r.GetDraft() {
+			return r
+		}
+	}
+	return nil
+}
+
+// getChangelog returns the changelog between the previous release and r
+// grouped according to mode, or nil if there is no previous release or r is
+// a draft.
+func getChangelog(ctx context.Context, client *github.Client, repo *github.Repository, mode string, previous, r *github.RepositoryRelease) (*changelog, error) {
+	if mode == "" || previous == nil || r.GetDraft() {
+		return nil, nil
+	}
+
+	key := repo.GetFullName() + " " + mode + " " + previous.GetTagName() + "..." + r.GetTagName()
+	changelogsMu.Lock()
+	c, ok := changelogs[key]
+	changelogsMu.Unlock()
+	if ok {
+		return c, nil
+	}
+
+	logFor(ctx).WithFields(logrus.Fields{
+		logFieldRepo: repo.GetFullName(),
+		logFieldTag:  r.GetTagName(),
+	}).Debug("Getting changelog...")
+	comparison, _, err := client.Repositories.CompareCommits(ctx, repo.GetOwner().GetLogin(), repo.GetName(), previous.GetTagName(), r.GetTagName())
+	if err != nil {
+		return nil, fmt.Errorf("comparing %s %s...%s failed: %v", repo.GetFullName(), previous.GetTagName(), r.GetTagName(), err)
+	}
+
+	var entries []changelogEntry
+	for _, commit := range comparison.Commits {
+		e := changelogEntry{
+			SHA:    commit.GetSHA(),
+			URL:    commit.GetHTMLURL(),
+			Author: commit.GetAuthor().GetLogin(),
+		}
+		if len(e.SHA) > 7 {
+			e.SHA = e.SHA[:7]
+		}
+
+		lines := strings.Split(strings.TrimSpace(commit.GetCommit().GetMessage()), "\n")
+		e.Title = strings.TrimSpace(lines[0])
+
+		if m := mergeCommitRegexp.FindStringSubmatch(e.Title); m != nil {
+			e.PullRequest, _ = strconv.Atoi(m[1])
+			// The pull request title is in the body of merge commits.
+			e.Title = strings.TrimSpace(strings.Join(lines[1:], " "))
+		} else if m := squashCommitRegexp.FindStringSubmatch(e.Title); m != nil {
+			e.PullRequest, _ = strconv.Atoi(m[1])
+			e.Title = strings.TrimSpace(strings.TrimSuffix(e.Title, m[0]))
+		}
+
+		// Only list merged pull requests, not every commit in them.
+		if mode == changelogLabels && e.PullRequest == 0 {
+			continue
+		}
+
+		entries = append(entries, e)
+	}
+
+	if mode == changelogLabels {
+		numbers := map[int]bool{}
+		for _, e := range entries {
+			numbers[e.PullRequest] = true
+		}
+		prs, err := getPullRequests(ctx, client, repo, numbers)
+		if err != nil {
+			return nil, err
+		}
+		for i, e := range entries {
+			pr, ok := prs[e.PullRequest]
+			if !ok {
+				continue
+			}
+			e.Title = pr.GetTitle()
+			e.URL = pr.GetHTMLURL()
+			e.Author = pr.GetUser().GetLogin()
+			for _, l := range pr.Labels {
+				e.Labels = append(e.Labels, l.GetName())
+			}
+			entries[i] = e
+		}
+	}
+
+	// Drop the entries without a title, like merge commits without one.
+	titled := entries[:0]
+	for _, e := range entries {
+		if e.Title != "" {
+			titled = append(titled, e)
+		}
+	}
+	entries = titled
+
+	c = &changelog{
+		Base:       previous.GetTagName(),
+		Head:       r.GetTagName(),
+		CompareURL: comparison.GetHTMLURL(),
+	}
+	if mode == changelogLabels {
+		c.Groups = groupByLabel(entries)
+	} else {
+		c.Groups = groupByConventionalType(entries)
+	}
+
+	changelogsMu.Lock()
+	changelogs[key] = c
+	changelogsMu.Unlock()
+
+	return c, nil
+}
+
+// getPullRequests returns the pull requests with the numbers, looking for
+// them in the most recently updated closed pull requests so a changelog
+// takes a few requests rather than one per pull request. Pull requests that
+// are not found are left out.
+func getPullRequests(ctx context.Context, client *github.Client, repo *github.Repository, numbers map[int]bool) (map[int]*github.PullRequest, error) {
+	prs := map[int]*github.PullRequest{}
+	opt := &github.PullRequestListOptions{
+		State:       "closed",
+		Sort:        "updated",
+		Direction:   "desc",
+		ListOptions: github.ListOptions{PerPage: 100},
+	}
+	for page := 0; page < changelogPullRequestPages && len(prs) < len(numbers); page++ {
+		list, resp, err := client.PullRequests.List(ctx, repo.GetOwner().GetLogin(), repo.GetName(), opt)
+		if err != nil {
+			return nil, fmt.Errorf("listing the pull requests of %s failed: %v", repo.GetFullName(), err)
+		}
+		for _, pr := range list {
+			if numbers[pr.GetNumber()] {
+				prs[pr.GetNumber()] = pr
+			}
+		}
+		if resp.NextPage == 0 {
+			break
+		}
+		opt.Page = resp.NextPage
+	}
+	return prs, nil
+}
+
+// groupByConventionalType groups the entries by their conventional commit
+// type, stripping the type from the title.
+func groupByConventionalType(entries []changelogEntry) []changelogGroup {
+	known := map[string]bool{}
+	for _, t := range conventionalTypes {
+		known[t.Type] = true
+	}
+
+	groups := map[string][]changelogEntry{}
+	var other []changelogEntry
+	for _, e := range entries {
+		m := conventionalCommitRegexp.FindStringSubmatch(e.Title)
+		if m == nil || !known[strings.ToLower(m[1])] {
+			other = append(other, e)
+			continue
+		}
+
+		e.Breaking = m[3] == "!"
+		e.Title = m[4]
+		groups[strings.ToLower(m[1])] = append(groups[strings.ToLower(m[1])], e)
+	}
+
+	var result []changelogGroup
+	for _, t := range conventionalTypes {
+		if len(groups[t.Type]) > 0 {
+			result = append(result, changelogGroup{Title: t.Title, Entries: groups[t.Type]})
+		}
+	}
+	if len(other) > 0 {
+		result = append(result, changelogGroup{Title: changelogOther, Entries: other})
+	}
+
+	return result
+}
+
+// groupByLabel groups the entries by the first label of their pull
+// request.
+func groupByLabel(entries []changelogEntry) []changelogGroup {
+	groups := map[string][]changelogEntry{}
+	var titles []string
+	var other []changelogEntry
+	for _, e := range entries {
+		if len(e.Labels) < 1 {
+			other = append(other, e)
+			continue
+		}
+
+		l := e.Labels[0]
+		if _, ok := groups[l]; !ok {
+			titles = append(titles, l)
+		}
+		groups[l] = append(groups[l], e)
+	}
+	sort.Strings(titles)
+
+	var result []changelogGroup
+	for _, t := range titles {
+		result = append(result, changelogGroup{Title: t, Entries: groups[t]})
+	}
+	if len(other) > 0 {
+		result = append(result, changelogGroup{Title: changelogOther, Entries: other})
+	}
+
+	return result
+}
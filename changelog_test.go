@@ -0,0 +1,113 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-github/github"
+)
+
+// groupsString returns the groups as "Title: entry, entry; Title: entry"
+// with a ! after the breaking entries.
+func groupsString(groups []changelogGroup) string {
+	var s []string
+	for _, g := range groups {
+		var entries []string
+		for _, e := range g.Entries {
+			if e.Breaking {
+				e.Title += "!"
+			}
+			entries = append(entries, e.Title)
+		}
+		s = append(s, g.Title+": "+strings.Join(entries, ", "))
+	}
+	return strings.Join(s, "; ")
+}
+
+func TestGroupByConventionalType(t *testing.T) {
+	testCases := []struct {
+		name    string
+		entries []string
+		want    string
+	}{
+		{
+			name: "none",
+			want: "",
+		},
+		{
+			name:    "ordered by type",
+			entries: []string{"docs: readme", "fix: crash", "feat: a", "feat(cli): b"},
+			want:    "Features: a, b; Bug Fixes: crash; Documentation: readme",
+		},
+		{
+			name:    "breaking",
+			entries: []string{"feat!: drop flag", "fix(api)!:  rename field"},
+			want:    "Features: drop flag!; Bug Fixes: rename field!",
+		},
+		{
+			name:    "case insensitive",
+			entries: []string{"Fix: crash", "FEAT: a"},
+			want:    "Features: a; Bug Fixes: crash",
+		},
+		{
+			name:    "other changes",
+			entries: []string{"Update readme", "wip: stuff", "fix: crash", "fix:"},
+			want:    "Bug Fixes: crash; Other Changes: Update readme, wip: stuff, fix:",
+		},
+	}
+
+	for _, tc := range testCases {
+		var entries []changelogEntry
+		for _, title := range tc.entries {
+			entries = append(entries, changelogEntry{Title: title})
+		}
+		if got := groupsString(groupByConventionalType(entries)); got != tc.want {
+			t.Errorf("%s: got %q, want %q", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestGroupByLabel(t *testing.T) {
+	entries := []changelogEntry{
+		{Title: "a", Labels: []string{"enhancement", "bug"}},
+		{Title: "b"},
+		{Title: "c", Labels: []string{"bug"}},
+		{Title: "d", Labels: []string{"enhancement"}},
+		{Title: "e", Labels: []string{"documentation"}},
+	}
+	want := "bug: c; documentation: e; enhancement: a, d; Other Changes: b"
+	if got := groupsString(groupByLabel(entries)); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestPreviousRelease(t *testing.T) {
+	r := func(tag string, draft bool) *github.RepositoryRelease {
+		return &github.RepositoryRelease{TagName: github.String(tag), Draft: github.Bool(draft)}
+	}
+	releases := []*github.RepositoryRelease{
+		r("v4", true),
+		r("v3", false),
+		r("v2", true),
+		r("v1", false),
+		r("v0", true),
+	}
+
+	testCases := []struct {
+		i    int
+		want string
+	}{
+		{0, "v3"},
+		{1, "v1"},
+		{2, "v1"},
+		{3, ""},
+		{4, ""},
+	}
+
+	for _, tc := range testCases {
+		got := previousRelease(releases, tc.i).GetTagName()
+		if got != tc.want {
+			t.Errorf("%s: got %q, want %q", releases[tc.i].GetTagName(), got, tc.want)
+		}
+	}
+}
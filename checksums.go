@@ -0,0 +1,109 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/go-github/github"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// checksumStateFile holds the contents of the checksum assets.
+	checksumStateFile = "checksums.json"
+
+	// checksumMaxAge is how long a checksum is kept after the asset was last
+	// seen.
+	checksumMaxAge = 30 * 24 * time.Hour
+)
+
+// savedChecksum is the content of a checksum asset.
+type savedChecksum struct {
+	Sum  string    `json:"sum"`
+	Seen time.Time `json:"seen"`
+}
+
+// checksumStore caches the contents of checksum assets, which do not change
+// once uploaded, so they are only downloaded once. It is persisted in the
+// state directory.
+type checksumStore struct {
+	mu     sync.Mutex
+	loaded bool
+	dirty  bool
+	// sums maps "{asset id}@{updated at}" to the content of the asset.
+	sums map[string]*savedChecksum
+}
+
+var checksums = &checksumStore{}
+
+// checksumKey identifies the content of an asset, it changes if the asset
+// is replaced.
+func checksumKey(asset *github.ReleaseAsset) string {
+	return fmt.Sprintf("%d@%s", asset.GetID(), asset.GetUpdatedAt().UTC().Format(time.RFC3339))
+}
+
+// load reads the checksums from the state directory the first time. If the
+// file cannot be read we start without them, they are only a cache.
+func (s *checksumStore) load() {
+	if s.loaded {
+		return
+	}
+	sums := map[string]*savedChecksum{}
+	if err := loadState(checksumStateFile, &sums); err != nil {
+		logrus.Warnf("loading %s failed, downloading the checksums again: %v", checksumStateFile, err)
+		sums = map[string]*savedChecksum{}
+	}
+	s.sums = sums
+	s.loaded = true
+}
+
+// get returns the content of the asset if we have it.
+func (s *checksumStore) get(asset *github.ReleaseAsset) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.load()
+	c, ok := s.sums[checksumKey(asset)]
+	if !ok {
+		return "", false
+	}
+	// Only mark it as seen once a day, so the file is not written on every
+	// refresh.
+	if now := time.Now(); now.Sub(c.Seen) > 24*time.Hour {
+		c.Seen = now
+		s.dirty = true
+	}
+	return c.Sum, true
+}
+
+// set stores the content of the asset.
+func (s *checksumStore) set(asset *github.ReleaseAsset, sum string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.load()
+	s.sums[checksumKey(asset)] = &savedChecksum{Sum: sum, Seen: time.Now()}
+	s.dirty = true
+}
+
+// save writes the checksums to the state directory if they changed. The
+// checksums of assets that were not seen for a while are dropped.
+func (s *checksumStore) save() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.dirty {
+		return nil
+	}
+	for key, c := range s.sums {
+		if time.Since(c.Seen) > checksumMaxAge {
+			delete(s.sums, key)
+		}
+	}
+	if err := saveState(checksumStateFile, s.sums); err != nil {
+		return err
+	}
+	s.dirty = false
+	return nil
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/github"
+)
+
+func testChecksumAsset(id int64, updated time.Time) *github.ReleaseAsset {
+	return &github.ReleaseAsset{
+		ID:        github.Int64(id),
+		UpdatedAt: &github.Timestamp{Time: updated},
+	}
+}
+
+func TestChecksumStore(t *testing.T) {
+	prevStateDir := stateDir
+	stateDir = t.TempDir()
+	t.Cleanup(func() { stateDir = prevStateDir })
+
+	updated := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	a := testChecksumAsset(1, updated)
+
+	s := &checksumStore{}
+	if _, ok := s.get(a); ok {
+		t.Fatal("got a checksum from an empty store")
+	}
+	s.set(a, "abc")
+	if sum, ok := s.get(a); !ok || sum != "abc" {
+		t.Errorf("got %q, %v, want abc", sum, ok)
+	}
+
+	// A replaced asset has a new key.
+	if _, ok := s.get(testChecksumAsset(1, updated.Add(time.Second))); ok {
+		t.Error("got the checksum of a replaced asset")
+	}
+
+	// The checksums of assets that were not seen for a while are dropped
+	// when saving.
+	old := testChecksumAsset(2, updated)
+	s.set(old, "def")
+	s.sums[checksumKey(old)].Seen = time.Now().Add(-checksumMaxAge - time.Hour)
+	if err := s.save(); err != nil {
+		t.Fatal(err)
+	}
+	if s.dirty {
+		t.Error("the store is still dirty after saving")
+	}
+
+	s = &checksumStore{}
+	if sum, ok := s.get(a); !ok || sum != "abc" {
+		t.Errorf("after reloading got %q, %v, want abc", sum, ok)
+	}
+	if _, ok := s.get(old); ok {
+		t.Error("the old checksum was not dropped")
+	}
+	if s.dirty {
+		t.Error("getting a checksum seen recently made the store dirty")
+	}
+}
+
+func TestChecksumStoreLoad(t *testing.T) {
+	prevStateDir := stateDir
+	t.Cleanup(func() { stateDir = prevStateDir })
+
+	a := testChecksumAsset(1, time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC))
+	saved, err := json.Marshal(map[string]*savedChecksum{
+		checksumKey(a): {Sum: "abc", Seen: time.Now()},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		name    string
+		content []byte
+		want    bool
+		// saved is the number of checksums saved after adding one.
+		saved int
+	}{
+		{name: "no file", saved: 1},
+		{name: "saved", content: saved, want: true, saved: 2},
+		{name: "empty", content: []byte{}, saved: 1},
+		{name: "corrupt", content: []byte(`{"1@2020-01-02T03:04:05Z": {"sum": "ab`), saved: 1},
+		{name: "not an object", content: []byte(`["abc"]`), saved: 1},
+	}
+
+	for _, tc := range testCases {
+		stateDir = t.TempDir()
+		p := filepath.Join(stateDir, checksumStateFile)
+		if tc.content != nil {
+			if err := ioutil.WriteFile(p, tc.content, 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		s := &checksumStore{}
+		if _, ok := s.get(a); ok != tc.want {
+			t.Errorf("%s: got a checksum %v, want %v", tc.name, ok, tc.want)
+		}
+
+		// A file that could not be read is replaced on the next save.
+		s.set(testChecksumAsset(2, time.Now()), "def")
+		if err := s.save(); err != nil {
+			t.Errorf("%s: saving failed: %v", tc.name, err)
+			continue
+		}
+		b, err := ioutil.ReadFile(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var sums map[string]*savedChecksum
+		if err := json.Unmarshal(b, &sums); err != nil {
+			t.Errorf("%s: the saved file is invalid: %v", tc.name, err)
+		}
+		if len(sums) != tc.saved {
+			t.Errorf("%s: got %d saved checksums, want %d", tc.name, len(sums), tc.saved)
+		}
+	}
+
+	// The state directory cannot be read.
+	stateDir = filepath.Join(t.TempDir(), "file")
+	if err := ioutil.WriteFile(stateDir, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	s := &checksumStore{}
+	if _, ok := s.get(a); ok {
+		t.Error("got a checksum from an unreadable state directory")
+	}
+	if s.sums == nil {
+		t.Error("the store was not started empty")
+	}
+}
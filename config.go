@@ -0,0 +1,467 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+)
+
+// defaultBinaryPattern is how binary assets are named unless the config
+// says otherwise.
+const defaultBinaryPattern = "{repo}-{os}-{arch}"
+
+var (
+	// include and exclude filter the repositories by owner/repo patterns.
+	include []string
+	exclude []string
+
+	// binaryPattern is the naming pattern of binary assets.
+	binaryPattern = defaultBinaryPattern
+
+	// repoOverrides are the [[repo]] blocks of the config in order.
+	repoOverrides []repoOverride
+)
+
+// configDoc is the layout of the config file. The keys that correspond to
+// flags are decoded as is and applied through the flags.
+type configDoc struct {
+	Port              interface{} `toml:"port"`
+	Interval          interface{} `toml:"interval"`
+	URL               interface{} `toml:"url"`
+	Orgs              interface{} `toml:"orgs"`
+	NoUser            interface{} `toml:"nouser"`
+	AppID             interface{} `toml:"app-id"`
+	AppKey            interface{} `toml:"app-key"`
+	AppInstallationID interface{} `toml:"app-installation-id"`
+	Anonymous         interface{} `toml:"anonymous"`
+	StateDir          interface{} `toml:"state-dir"`
+	Cache             interface{} `toml:"cache"`
+	CacheDir          interface{} `toml:"cache-dir"`
+	CacheMaxSize      interface{} `toml:"cache-max-size"`
+	ReadyIntervals    interface{} `toml:"ready-intervals"`
+	DownloadTimeout   interface{} `toml:"download-timeout"`
+	StaleAfter        interface{} `toml:"stale-after"`
+	Notify            interface{} `toml:"notify"`
+	ManifestDir       interface{} `toml:"manifest-dir"`
+	TemplateDir       interface{} `toml:"template-dir"`
+
+	Update struct {
+		Mode      interface{} `toml:"mode"`
+		Latest    interface{} `toml:"latest"`
+		Since     interface{} `toml:"since"`
+		Workers   interface{} `toml:"workers"`
+		Changelog interface{} `toml:"changelog"`
+	} `toml:"update"`
+
+	Platforms []string     `toml:"platforms"`
+	Include   []string     `toml:"include"`
+	Exclude   []string     `toml:"exclude"`
+	Binary    *string      `toml:"binary"`
+	Repo      []repoConfig `toml:"repo"`
+}
+
+// repoConfig is a [[repo]] block of the config file.
+type repoConfig struct {
+	Name         string   `toml:"name"`
+	Skip         *bool    `toml:"skip"`
+	Update       *bool    `toml:"update"`
+	Platforms    []string `toml:"platforms"`
+	Binary       *string  `toml:"binary"`
+	StaleAfter   *string  `toml:"stale-after"`
+	UpdateLatest *int     `toml:"update-latest"`
+	UpdateSince  *string  `toml:"update-since"`
+	Changelog    *string  `toml:"changelog"`
+}
+
+// configFlag is a key of the config file that sets a flag.
+type configFlag struct {
+	key   string
+	flag  string
+	value interface{}
+}
+
+// flags returns the keys that set flags, keys in a table are prefixed with
+// the table name. The value is nil if the key is not in the file.
+func (c *configDoc) flags() []configFlag {
+	return []configFlag{
+		{"port", "port", c.Port},
+		{"interval", "interval", c.Interval},
+		{"url", "url", c.URL},
+		{"orgs", "orgs", c.Orgs},
+		{"nouser", "nouser", c.NoUser},
+		{"app-id", "app-id", c.AppID},
+		{"app-key", "app-key", c.AppKey},
+		{"app-installation-id", "app-installation-id", c.AppInstallationID},
+		{"anonymous", "anonymous", c.Anonymous},
+		{"state-dir", "state-dir", c.StateDir},
+		{"cache", "cache", c.Cache},
+		{"cache-dir", "cache-dir", c.CacheDir},
+		{"cache-max-size", "cache-max-size", c.CacheMaxSize},
+		{"ready-intervals", "ready-intervals", c.ReadyIntervals},
+		{"download-timeout", "download-timeout", c.DownloadTimeout},
+		{"stale-after", "stale-after", c.StaleAfter},
+		{"notify", "notify", c.Notify},
+		{"manifest-dir", "manifest-dir", c.ManifestDir},
+		{"template-dir", "template-dir", c.TemplateDir},
+		{"update.mode", "update-release-body", c.Update.Mode},
+		{"update.latest", "update-latest", c.Update.Latest},
+		{"update.since", "update-since", c.Update.Since},
+		{"update.workers", "update-workers", c.Update.Workers},
+		{"update.changelog", "changelog", c.Update.Changelog},
+	}
+}
+
+// repoOverride holds the settings of a [[repo]] block, nil fields are not
+// overridden.
+type repoOverride struct {
+	name         string
+	skip         *bool
+	platforms    []string
+	binary       *string
+	staleAfter   *time.Duration
+	update       *bool
+	updateLatest *int
+	updateSince  *time.Time
+	changelog    *string
+}
+
+// repoSettings are the settings for a single repository after applying the
+// matching [[repo]] blocks to the global settings.
+type repoSettings struct {
+	skip         bool
+	platforms    []string
+	binary       string
+	staleAfter   time.Duration
+	update       bool
+	updateLatest int
+	updateSince  time.Time
+	changelog    string
+}
+
+// settingsFor returns the settings for the repository owner/repo.
+func settingsFor(fullName string) repoSettings {
+	configMu.RLock()
+	defer configMu.RUnlock()
+
+	s := repoSettings{
+		platforms:    platforms,
+		binary:       binaryPattern,
+		staleAfter:   staleAfter,
+		update:       true,
+		updateLatest: updateLatest,
+		updateSince:  updateSinceTime,
+		changelog:    changelogMode,
+	}
+
+	// Repositories that are not included or are excluded are skipped.
+	if (len(include) > 0 && !matchRepo(include, fullName)) || matchRepo(exclude, fullName) {
+		s.skip = true
+	}
+
+	for _, o := range repoOverrides {
+		if !matchRepo([]string{o.name}, fullName) {
+			continue
+		}
+		if o.skip != nil {
+			s.skip = *o.skip
+		}
+		if o.platforms != nil {
+			s.platforms = o.platforms
+		}
+		if o.binary != nil {
+			s.binary = *o.binary
+		}
+		if o.staleAfter != nil {
+			s.staleAfter = *o.staleAfter
+		}
+		if o.update != nil {
+			s.update = *o.update
+		}
+		if o.updateLatest != nil {
+			s.updateLatest = *o.updateLatest
+		}
+		if o.updateSince != nil {
+			s.updateSince = *o.updateSince
+		}
+		if o.changelog != nil {
+			s.changelog = *o.changelog
+		}
+	}
+	return s
+}
+
+// matchRepo returns if the repository owner/repo matches one of the
+// patterns, like owner/repo or owner/*.
+func matchRepo(patterns []string, fullName string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(strings.ToLower(pattern), strings.ToLower(fullName)); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// loadConfig reads the config file at p and applies it. Flags that were set
+// on the command line take precedence over the config file.
+func loadConfig(p string, fs *flag.FlagSet) error {
+	var c configDoc
+	md, err := toml.DecodeFile(p, &c)
+	if err != nil {
+		return fmt.Errorf("%s: %v", p, err)
+	}
+	if keys := md.Undecoded(); len(keys) > 0 {
+		return configErrorf(p, keys[0].String(), "unknown key")
+	}
+
+	set := map[string]bool{}
+	fs.Visit(func(f *flag.Flag) {
+		set[f.Name] = true
+	})
+	// -p is an alias of --port.
+	if set["p"] {
+		set["port"] = true
+	}
+
+	// Apply the keys that correspond to flags through the flags themselves,
+	// so they are validated the same way.
+	for _, cf := range c.flags() {
+		var values []string
+		if cf.value != nil {
+			if values, err = configStrings(p, cf.key, cf.value); err != nil {
+				return err
+			}
+		}
+		if set[cf.flag] {
+			continue
+		}
+
+		// Start from the default, so keys removed from the config are reset
+		// when it is reloaded.
+		fl := fs.Lookup(cf.flag)
+		if s, ok := fl.Value.(*stringSlice); ok {
+			*s = nil
+		} else if err := fl.Value.Set(fl.DefValue); err != nil {
+			return err
+		}
+
+		if cf.value == nil {
+			continue
+		}
+		if _, ok := fl.Value.(*stringSlice); !ok && len(values) != 1 {
+			return configErrorf(p, cf.key, "must be a single value, not an array")
+		}
+		for _, v := range values {
+			if err := fl.Value.Set(v); err != nil {
+				return configErrorf(p, cf.key, "invalid value %q: %v", v, err)
+			}
+		}
+	}
+
+	// The platforms in the config are a list rather than a file.
+	if md.IsDefined("platforms") && !set["platforms"] {
+		if err := validatePlatforms(c.Platforms); err != nil {
+			return configErrorf(p, "platforms", "%v", err)
+		}
+		platforms = c.Platforms
+	}
+
+	if err := validatePatterns(p, "include", c.Include); err != nil {
+		return err
+	}
+	if err := validatePatterns(p, "exclude", c.Exclude); err != nil {
+		return err
+	}
+	include, exclude = c.Include, c.Exclude
+
+	binaryPattern = defaultBinaryPattern
+	if c.Binary != nil {
+		if err := validateBinaryPattern(*c.Binary); err != nil {
+			return configErrorf(p, "binary", "%v", err)
+		}
+		binaryPattern = *c.Binary
+	}
+
+	repoOverrides = nil
+	for i, rc := range c.Repo {
+		o, err := parseRepoOverride(p, fmt.Sprintf("repo[%d]", i), rc)
+		if err != nil {
+			return err
+		}
+		repoOverrides = append(repoOverrides, o)
+	}
+
+	return nil
+}
+
+// configStrings returns the value of a key that sets a flag as the strings
+// to set the flag to. A single value is returned as a slice of one.
+func configStrings(file, key string, v interface{}) ([]string, error) {
+	a, isArray := v.([]interface{})
+	if !isArray {
+		a = []interface{}{v}
+	}
+	s := make([]string, 0, len(a))
+	for _, e := range a {
+		switch e := e.(type) {
+		case string:
+			s = append(s, e)
+		case int64:
+			s = append(s, strconv.FormatInt(e, 10))
+		case bool:
+			s = append(s, strconv.FormatBool(e))
+		default:
+			return nil, configErrorf(file, key, "must be a string, integer, boolean or an array of those")
+		}
+	}
+	return s, nil
+}
+
+// configErrorf returns an error about key in the config file.
+func configErrorf(file, key, format string, args ...interface{}) error {
+	return fmt.Errorf("%s: %s: %s", file, key, fmt.Sprintf(format, args...))
+}
+
+// parseRepoOverride validates a [[repo]] block, key is its name in errors.
+func parseRepoOverride(file, key string, rc repoConfig) (repoOverride, error) {
+	o := repoOverride{
+		name:      rc.Name,
+		skip:      rc.Skip,
+		update:    rc.Update,
+		platforms: rc.Platforms,
+		binary:    rc.Binary,
+		changelog: rc.Changelog,
+	}
+
+	if rc.Name == "" {
+		return o, configErrorf(file, key, "[[repo]] must set a name")
+	}
+	if err := validatePatterns(file, key+".name", []string{rc.Name}); err != nil {
+		return o, err
+	}
+
+	if err := validatePlatforms(rc.Platforms); err != nil {
+		return o, configErrorf(file, key+".platforms", "%v", err)
+	}
+
+	if rc.Binary != nil {
+		if err := validateBinaryPattern(*rc.Binary); err != nil {
+			return o, configErrorf(file, key+".binary", "%v", err)
+		}
+	}
+
+	if rc.StaleAfter != nil {
+		d, err := time.ParseDuration(*rc.StaleAfter)
+		if err != nil {
+			return o, configErrorf(file, key+".stale-after", "invalid duration %q", *rc.StaleAfter)
+		}
+		o.staleAfter = &d
+	}
+
+	if rc.UpdateLatest != nil {
+		if *rc.UpdateLatest < 0 {
+			return o, configErrorf(file, key+".update-latest", "must be a number of releases, got %d", *rc.UpdateLatest)
+		}
+		o.updateLatest = rc.UpdateLatest
+	}
+
+	if rc.UpdateSince != nil {
+		d, err := time.Parse("2006-01-02", *rc.UpdateSince)
+		if err != nil {
+			return o, configErrorf(file, key+".update-since", "invalid date %q, expected YYYY-MM-DD", *rc.UpdateSince)
+		}
+		o.updateSince = &d
+	}
+
+	if rc.Changelog != nil {
+		if v := *rc.Changelog; v != "" && v != changelogConventional && v != changelogLabels {
+			return o, configErrorf(file, key+".changelog", "must be %q, %q or empty", changelogConventional, changelogLabels)
+		}
+	}
+
+	return o, nil
+}
+
+// validatePatterns checks the owner/repo patterns of key.
+func validatePatterns(file, key string, patterns []string) error {
+	for _, pattern := range patterns {
+		if len(strings.Split(pattern, "/")) != 2 {
+			return configErrorf(file, key, "%q must be of the form owner/repo or owner/*", pattern)
+		}
+		if _, err := path.Match(pattern, ""); err != nil {
+			return configErrorf(file, key, "invalid pattern %q: %v", pattern, err)
+		}
+	}
+	return nil
+}
+
+// validatePlatforms checks that every platform is of the form os/arch.
+func validatePlatforms(platforms []string) error {
+	for _, p := range platforms {
+		s := strings.SplitN(p, "/", 2)
+		if len(s) != 2 || s[0] == "" || s[1] == "" {
+			return fmt.Errorf("%q is not of the form os/arch", p)
+		}
+	}
+	return nil
+}
+
+// validateBinaryPattern checks that a binary naming pattern contains the
+// placeholders needed to find the platform of a binary.
+func validateBinaryPattern(pattern string) error {
+	for _, p := range []string{"{os}", "{arch}"} {
+		if strings.Count(pattern, p) != 1 {
+			return fmt.Errorf("%q must contain %s exactly once", pattern, p)
+		}
+	}
+	return nil
+}
+
+// binaryMatcher finds the platform of the binaries of a repository from
+// their names.
+type binaryMatcher struct {
+	pattern string
+	repo    string
+	re      *regexp.Regexp
+}
+
+func newBinaryMatcher(pattern, repo string) *binaryMatcher {
+	expr := regexp.QuoteMeta(pattern)
+	expr = strings.Replace(expr, regexp.QuoteMeta("{repo}"), regexp.QuoteMeta(repo), -1)
+	expr = strings.Replace(expr, regexp.QuoteMeta("{os}"), `(?P<os>[^-_.]+)`, 1)
+	expr = strings.Replace(expr, regexp.QuoteMeta("{arch}"), `(?P<arch>[^.]+?)`, 1)
+	return &binaryMatcher{
+		pattern: pattern,
+		repo:    repo,
+		re:      regexp.MustCompile("^" + expr + "$"),
+	}
+}
+
+// platform returns the os and arch of the binary name, both are empty if
+// name is not a binary.
+func (m *binaryMatcher) platform(name string) (string, string) {
+	match := m.re.FindStringSubmatch(name)
+	if match == nil {
+		return "", ""
+	}
+	var osn, arch string
+	for i, n := range m.re.SubexpNames() {
+		switch n {
+		case "os":
+			osn = match[i]
+		case "arch":
+			arch = match[i]
+		}
+	}
+	return osn, arch
+}
+
+// name returns the name of the binary for the platform.
+func (m *binaryMatcher) name(osn, arch string) string {
+	return strings.NewReplacer("{repo}", m.repo, "{os}", osn, "{arch}", arch).Replace(m.pattern)
+}
@@ -0,0 +1,391 @@
+package main
+
+import (
+	"flag"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testGlobals points to the globals set by the flags and the config file.
+// Registering the flags here sets them to their defaults, like the program
+// does before it parses them.
+var testGlobals = func() []reflect.Value {
+	fs := flag.NewFlagSet("globals", flag.ContinueOnError)
+	registerFlags(fs)
+
+	var globals []reflect.Value
+	fs.VisitAll(func(f *flag.Flag) {
+		globals = append(globals, reflect.ValueOf(f.Value).Elem())
+	})
+	for _, p := range []interface{}{&include, &exclude, &binaryPattern, &repoOverrides, &platforms} {
+		globals = append(globals, reflect.ValueOf(p).Elem())
+	}
+	return globals
+}()
+
+// testFlagSet returns a flag set with the flags of the program. The globals
+// the flags and the config file set are restored when the test ends.
+func testFlagSet(t *testing.T) *flag.FlagSet {
+	saved := make([]reflect.Value, len(testGlobals))
+	for i, g := range testGlobals {
+		saved[i] = reflect.New(g.Type()).Elem()
+		saved[i].Set(g)
+	}
+	t.Cleanup(func() {
+		for i, g := range testGlobals {
+			g.Set(saved[i])
+		}
+	})
+
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	registerFlags(fs)
+	return fs
+}
+
+// writeConfig writes the config to a file and returns its path.
+func writeConfig(t *testing.T, config string) string {
+	p := filepath.Join(t.TempDir(), "config.toml")
+	if err := ioutil.WriteFile(p, []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestLoadConfig(t *testing.T) {
+	testCases := []struct {
+		name   string
+		config string
+		err    string
+	}{
+		{
+			name: "valid",
+			config: `
+interval = "30m"
+orgs = ["genuinetools", "jessfraz"]
+nouser = true
+port = 9000
+cache-max-size = "1GB"
+include = ["genuinetools/*"]
+exclude = ["genuinetools/pkg"]
+platforms = ["linux/amd64", "darwin/amd64"]
+binary = "{repo}_{os}_{arch}"
+
+[update]
+mode = "dry-run"
+latest = 5
+since = "2020-01-01"
+changelog = "conventional"
+
+[[repo]]
+name = "genuinetools/img"
+skip = false
+update = true
+stale-after = "4380h"
+update-latest = 1
+update-since = "2021-01-01"
+changelog = "labels"
+`,
+		},
+		{
+			name:   "syntax error",
+			config: "interval = \n",
+			err:    "toml: line 2",
+		},
+		{
+			name:   "unknown key",
+			config: "bogus = 1\n",
+			err:    "bogus: unknown key",
+		},
+		{
+			name:   "unknown key in a table",
+			config: "[update]\nbogus = 1\n",
+			err:    "update.bogus: unknown key",
+		},
+		{
+			name:   "unknown table",
+			config: "[bogus]\n",
+			err:    "bogus: unknown key",
+		},
+		{
+			name:   "invalid flag value",
+			config: `interval = "often"`,
+			err:    `interval: invalid value "often"`,
+		},
+		{
+			name:   "array for a single value",
+			config: `interval = ["1h", "2h"]`,
+			err:    "interval: must be a single value",
+		},
+		{
+			name:   "unsupported value",
+			config: "interval = 1.5\n",
+			err:    "interval: must be a string, integer, boolean or an array of those",
+		},
+		{
+			name:   "invalid pattern",
+			config: `include = ["genuinetools"]`,
+			err:    `include: "genuinetools" must be of the form owner/repo`,
+		},
+		{
+			name:   "invalid platform",
+			config: `platforms = ["linux"]`,
+			err:    `platforms: "linux" is not of the form os/arch`,
+		},
+		{
+			name:   "invalid binary pattern",
+			config: `binary = "{repo}-{os}"`,
+			err:    "binary: \"{repo}-{os}\" must contain {arch} exactly once",
+		},
+		{
+			name:   "repo without a name",
+			config: "[[repo]]\nskip = true\n",
+			err:    "repo[0]: [[repo]] must set a name",
+		},
+		{
+			name:   "wrong type in a repo",
+			config: "[[repo]]\nname = \"a/b\"\nskip = \"yes\"\n",
+			err:    "line 3",
+		},
+		{
+			name:   "invalid duration in a repo",
+			config: "[[repo]]\nname = \"a/b\"\n\n[[repo]]\nname = \"a/c\"\nstale-after = \"long\"\n",
+			err:    `repo[1].stale-after: invalid duration "long"`,
+		},
+		{
+			name:   "negative update-latest in a repo",
+			config: "[[repo]]\nname = \"a/b\"\nupdate-latest = -1\n",
+			err:    "repo[0].update-latest: must be a number of releases",
+		},
+		{
+			name:   "invalid date in a repo",
+			config: "[[repo]]\nname = \"a/b\"\nupdate-since = \"yesterday\"\n",
+			err:    `repo[0].update-since: invalid date "yesterday"`,
+		},
+		{
+			name:   "invalid changelog in a repo",
+			config: "[[repo]]\nname = \"a/b\"\nchangelog = \"all\"\n",
+			err:    `repo[0].changelog: must be "conventional", "labels" or empty`,
+		},
+	}
+
+	for _, tc := range testCases {
+		err := loadConfig(writeConfig(t, tc.config), testFlagSet(t))
+		switch {
+		case tc.err == "" && err != nil:
+			t.Errorf("%s: unexpected error: %v", tc.name, err)
+		case tc.err != "" && err == nil:
+			t.Errorf("%s: expected an error containing %q", tc.name, tc.err)
+		case tc.err != "" && !strings.Contains(err.Error(), tc.err):
+			t.Errorf("%s: got error %q, want it to contain %q", tc.name, err, tc.err)
+		}
+	}
+}
+
+func TestLoadConfigPrecedence(t *testing.T) {
+	fs := testFlagSet(t)
+	if err := fs.Parse([]string{"--interval", "5m", "-p", "9999"}); err != nil {
+		t.Fatal(err)
+	}
+
+	p := writeConfig(t, `
+interval = "30m"
+port = 9000
+orgs = ["genuinetools"]
+stale-after = "1h"
+
+[update]
+latest = 5
+`)
+	if err := loadConfig(p, fs); err != nil {
+		t.Fatal(err)
+	}
+	if interval != 5*time.Minute {
+		t.Errorf("the interval flag should win over the config, got %s", interval)
+	}
+	if port != 9999 {
+		t.Errorf("the -p flag should win over the config, got %d", port)
+	}
+	if strings.Join(orgs, ",") != "genuinetools" {
+		t.Errorf("orgs should be set from the config, got %v", orgs)
+	}
+	if staleAfter != time.Hour || updateLatest != 5 {
+		t.Errorf("stale-after and update.latest should be set from the config, got %s and %d", staleAfter, updateLatest)
+	}
+
+	// Keys removed from the config are reset to their defaults on reload.
+	if err := ioutil.WriteFile(p, []byte(`orgs = ["jessfraz"]`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := loadConfig(p, fs); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(orgs, ",") != "jessfraz" {
+		t.Errorf("orgs should be replaced on reload, got %v", orgs)
+	}
+	if staleAfter != 365*24*time.Hour || updateLatest != 0 {
+		t.Errorf("removed keys should be reset on reload, got stale-after %s and update.latest %d", staleAfter, updateLatest)
+	}
+	if interval != 5*time.Minute {
+		t.Errorf("the interval flag should still win on reload, got %s", interval)
+	}
+}
+
+func TestSettingsFor(t *testing.T) {
+	p := writeConfig(t, `
+include = ["genuinetools/*", "jessfraz/dockerfiles"]
+exclude = ["genuinetools/pkg"]
+platforms = ["linux/amd64"]
+stale-after = "100h"
+
+[update]
+changelog = "conventional"
+
+[[repo]]
+name = "genuinetools/*"
+binary = "{repo}_{os}_{arch}"
+update-latest = 3
+
+[[repo]]
+name = "genuinetools/img"
+platforms = ["linux/amd64", "linux/arm64"]
+update-latest = 1
+changelog = ""
+
+[[repo]]
+name = "genuinetools/reg"
+update = false
+skip = true
+
+[[repo]]
+name = "genuinetools/pkg"
+skip = false
+`)
+	if err := loadConfig(p, testFlagSet(t)); err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		repo         string
+		skip         bool
+		update       bool
+		platforms    string
+		binary       string
+		updateLatest int
+		changelog    string
+	}{
+		{
+			repo:      "jessfraz/dockerfiles",
+			update:    true,
+			platforms: "linux/amd64",
+			binary:    defaultBinaryPattern,
+			changelog: changelogConventional,
+		},
+		{
+			repo:      "jessfraz/other",
+			skip:      true,
+			update:    true,
+			platforms: "linux/amd64",
+			binary:    defaultBinaryPattern,
+			changelog: changelogConventional,
+		},
+		{
+			repo:         "genuinetools/weather",
+			update:       true,
+			platforms:    "linux/amd64",
+			binary:       "{repo}_{os}_{arch}",
+			updateLatest: 3,
+			changelog:    changelogConventional,
+		},
+		{
+			// The later blocks override the earlier ones.
+			repo:         "genuinetools/img",
+			update:       true,
+			platforms:    "linux/amd64,linux/arm64",
+			binary:       "{repo}_{os}_{arch}",
+			updateLatest: 1,
+		},
+		{
+			repo:         "genuinetools/reg",
+			skip:         true,
+			platforms:    "linux/amd64",
+			binary:       "{repo}_{os}_{arch}",
+			updateLatest: 3,
+			changelog:    changelogConventional,
+		},
+		{
+			// A block can show an excluded repository again.
+			repo:         "genuinetools/pkg",
+			update:       true,
+			platforms:    "linux/amd64",
+			binary:       "{repo}_{os}_{arch}",
+			updateLatest: 3,
+			changelog:    changelogConventional,
+		},
+	}
+
+	for _, tc := range testCases {
+		s := settingsFor(tc.repo)
+		if s.skip != tc.skip {
+			t.Errorf("%s: got skip %v, want %v", tc.repo, s.skip, tc.skip)
+		}
+		if s.update != tc.update {
+			t.Errorf("%s: got update %v, want %v", tc.repo, s.update, tc.update)
+		}
+		if strings.Join(s.platforms, ",") != tc.platforms {
+			t.Errorf("%s: got platforms %v, want %s", tc.repo, s.platforms, tc.platforms)
+		}
+		if s.binary != tc.binary {
+			t.Errorf("%s: got binary %q, want %q", tc.repo, s.binary, tc.binary)
+		}
+		if s.updateLatest != tc.updateLatest {
+			t.Errorf("%s: got update-latest %d, want %d", tc.repo, s.updateLatest, tc.updateLatest)
+		}
+		if s.changelog != tc.changelog {
+			t.Errorf("%s: got changelog %q, want %q", tc.repo, s.changelog, tc.changelog)
+		}
+		if s.staleAfter != 100*time.Hour {
+			t.Errorf("%s: got stale-after %s, want 100h", tc.repo, s.staleAfter)
+		}
+	}
+}
+
+func TestBinaryMatcher(t *testing.T) {
+	testCases := []struct {
+		pattern string
+		repo    string
+		name    string
+		os      string
+		arch    string
+	}{
+		{"{repo}-{os}-{arch}", "img", "img-linux-amd64", "linux", "amd64"},
+		{"{repo}-{os}-{arch}", "img", "img-windows-amd64.exe", "", ""},
+		{"{repo}-{os}-{arch}", "img", "img-linux-arm-7", "linux", "arm-7"},
+		{"{repo}-{os}-{arch}", "img", "img-linux-amd64.sha256", "", ""},
+		{"{repo}-{os}-{arch}", "img", "reg-linux-amd64", "", ""},
+		{"{repo}-{os}-{arch}", "img", "img-linux", "", ""},
+		{"{repo}-{os}-{arch}", "img.go", "img.go-linux-amd64", "linux", "amd64"},
+		{"{repo}-{os}-{arch}", "img.go", "imgxgo-linux-amd64", "", ""},
+		{"{repo}_{os}_{arch}", "img", "img_darwin_arm64", "darwin", "arm64"},
+		{"{repo}_{os}_{arch}.tar.gz", "img", "img_darwin_arm64.tar.gz", "darwin", "arm64"},
+		{"{repo}_{os}_{arch}.tar.gz", "img", "img_darwin_arm64.zip", "", ""},
+		{"{os}-{arch}", "img", "linux-386", "linux", "386"},
+	}
+
+	for _, tc := range testCases {
+		m := newBinaryMatcher(tc.pattern, tc.repo)
+		osn, arch := m.platform(tc.name)
+		if osn != tc.os || arch != tc.arch {
+			t.Errorf("%s %s: got %q/%q, want %q/%q", tc.pattern, tc.name, osn, arch, tc.os, tc.arch)
+		}
+	}
+
+	// name is the reverse of platform.
+	m := newBinaryMatcher("{repo}_{os}_{arch}.tar.gz", "img")
+	if name := m.name("linux", "amd64"); name != "img_linux_amd64.tar.gz" {
+		t.Errorf("got name %q, want img_linux_amd64.tar.gz", name)
+	}
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDiffLines(t *testing.T) {
+	testCases := []struct {
+		name string
+		a, b string
+		want string
+	}{
+		{
+			name: "equal",
+			a:    "a b c",
+			b:    "a b c",
+			want: " a  b  c",
+		},
+		{
+			name: "empty a",
+			a:    "",
+			b:    "a b",
+			want: "+a +b",
+		},
+		{
+			name: "empty b",
+			a:    "a b",
+			b:    "",
+			want: "-a -b",
+		},
+		{
+			name: "changed line",
+			a:    "a b c",
+			b:    "a x c",
+			want: " a -b +x  c",
+		},
+		{
+			name: "insert and delete",
+			a:    "a b c d",
+			b:    "b c e d",
+			want: "-a  b  c +e  d",
+		},
+		{
+			name: "longest common subsequence",
+			a:    "a b c a b b a",
+			b:    "c b a b a c",
+			want: "-a -b  c -a  b +a  b  a +c",
+		},
+	}
+
+	for _, tc := range testCases {
+		var got []string
+		for _, op := range diffLines(strings.Fields(tc.a), strings.Fields(tc.b)) {
+			got = append(got, string(op.kind)+op.line)
+		}
+		if strings.Join(got, " ") != tc.want {
+			t.Errorf("%s: got %q, want %q", tc.name, strings.Join(got, " "), tc.want)
+		}
+	}
+}
+
+func TestUnifiedDiff(t *testing.T) {
+	lines := func(n int, change map[int]string) string {
+		var s []string
+		for i := 1; i <= n; i++ {
+			l := string(rune('a' + i - 1))
+			if c, ok := change[i]; ok {
+				l = c
+			}
+			if l != "" {
+				s = append(s, l)
+			}
+		}
+		return strings.Join(s, "\n") + "\n"
+	}
+
+	testCases := []struct {
+		name string
+		a, b string
+		want string
+	}{
+		{
+			name: "equal",
+			a:    lines(5, nil),
+			b:    lines(5, nil),
+			want: "",
+		},
+		{
+			name: "one hunk",
+			a:    lines(10, nil),
+			b:    lines(10, map[int]string{5: "X"}),
+			want: `--- a
++++ b
+@@ -2,7 +2,7 @@
+ b
+ c
+ d
+-e
++X
+ f
+ g
+ h
+`,
+		},
+		{
+			name: "two hunks",
+			a:    lines(20, nil),
+			b:    lines(20, map[int]string{2: "X", 18: ""}),
+			want: `--- a
++++ b
+@@ -1,5 +1,5 @@
+ a
+-b
++X
+ c
+ d
+ e
+@@ -15,6 +15,5 @@
+ o
+ p
+ q
+-r
+ s
+ t
+`,
+		},
+		{
+			name: "close changes share a hunk",
+			a:    lines(10, nil),
+			b:    lines(10, map[int]string{2: "X", 8: "Y"}),
+			want: `--- a
++++ b
+@@ -1,10 +1,10 @@
+ a
+-b
++X
+ c
+ d
+ e
+ f
+ g
+-h
++Y
+ i
+ j
+`,
+		},
+		{
+			name: "from empty",
+			a:    "",
+			b:    "a\nb\n",
+			want: `--- a
++++ b
+@@ -0,0 +1,2 @@
++a
++b
+`,
+		},
+		{
+			name: "windows line endings",
+			a:    "a\r\nb\r\n",
+			b:    "a\nc\n",
+			want: `--- a
++++ b
+@@ -1,2 +1,2 @@
+ a
+-b
++c
+`,
+		},
+	}
+
+	for _, tc := range testCases {
+		if got := unifiedDiff(tc.a, tc.b, "a", "b"); got != tc.want {
+			t.Errorf("%s: got\n%s\nwant\n%s", tc.name, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"time"
+
+	units "github.com/docker/go-units"
+	"github.com/google/go-github/github"
+)
+
+const (
+	// downloadAttempts is how often downloading an asset is tried before
+	// giving up, waiting about downloadBackoff times two to the attempt in
+	// between.
+	downloadAttempts = 4
+	downloadBackoff  = time.Second
+
+	// checksumMaxSize is the largest checksum asset we download.
+	checksumMaxSize = 64 * units.KiB
+)
+
+var (
+	// downloadTimeout is how long a single attempt to download an asset
+	// may take.
+	downloadTimeout time.Duration
+
+	// downloadClient fetches the assets GitHub redirects to. It is neither
+	// cached nor authenticated.
+	downloadClient = http.DefaultClient
+)
+
+// retryableError is a download error that is worth trying again, like a
+// server or connection error.
+type retryableError struct {
+	err error
+}
+
+func (e *retryableError) Error() string { return e.err.Error() }
+
+// downloadAsset returns the content of the asset with the id, retrying on
+// server and connection errors.
+func downloadAsset(ctx context.Context, client *github.Client, repo *github.Repository, id int64) ([]byte, error) {
+	var err error
+	for attempt := 0; attempt < downloadAttempts; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-time.After(jitter(downloadBackoff << uint(attempt-1))):
+			}
+		}
+
+		var b []byte
+		if b, err = downloadAssetOnce(ctx, client, repo, id); err == nil {
+			return b, nil
+		}
+		if _, ok := err.(*retryableError); !ok || ctx.Err() != nil {
+			return nil, err
+		}
+		logFor(ctx).Debugf("Downloading the asset failed (attempt %d of %d): %v", attempt+1, downloadAttempts, err)
+	}
+	return nil, err
+}
+
+func downloadAssetOnce(ctx context.Context, client *github.Client, repo *github.Repository, id int64) ([]byte, error) {
+	configMu.RLock()
+	hc, timeout := downloadClient, downloadTimeout
+	configMu.RUnlock()
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	body, redirectURL, err := client.Repositories.DownloadReleaseAsset(ctx, repo.GetOwner().GetLogin(), repo.GetName(), id)
+	if err != nil {
+		return nil, classifyDownloadError(err)
+	}
+	if body == nil && len(redirectURL) > 0 {
+		// The redirect is to a signed url, so it must not get the GitHub
+		// token and is left out of the errors.
+		req, err := http.NewRequest(http.MethodGet, redirectURL, nil)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := hc.Do(req.WithContext(ctx))
+		if err != nil {
+			if v, ok := err.(*url.Error); ok {
+				err = v.Err
+			}
+			return nil, &retryableError{fmt.Errorf("getting the redirect failed: %v", err)}
+		}
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			err := fmt.Errorf("getting the redirect failed: %s", resp.Status)
+			if resp.StatusCode >= 500 {
+				return nil, &retryableError{err}
+			}
+			return nil, err
+		}
+		body = resp.Body
+	}
+	if body == nil {
+		return nil, errors.New("body for asset was nil")
+	}
+	defer body.Close()
+
+	b, err := ioutil.ReadAll(io.LimitReader(body, checksumMaxSize+1))
+	if err != nil {
+		return nil, &retryableError{err}
+	}
+	if len(b) > checksumMaxSize {
+		return nil, fmt.Errorf("asset is larger than %s", units.BytesSize(checksumMaxSize))
+	}
+	return b, nil
+}
+
+// classifyDownloadError marks server and connection errors as retryable.
+// That includes the timeout of an attempt, downloadAsset stops if its own
+// context is done.
+func classifyDownloadError(err error) error {
+	if v, ok := err.(*github.ErrorResponse); ok && v.Response != nil && v.Response.StatusCode >= 500 {
+		return &retryableError{err}
+	}
+	if _, ok := err.(*url.Error); ok {
+		return &retryableError{err}
+	}
+	return err
+}
+
+// jitter returns a random duration between half and one and a half times
+// d, so retries are spread out.
+func jitter(d time.Duration) time.Duration {
+	return d/2 + time.Duration(rand.Int63n(int64(d)))
+}
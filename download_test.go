@@ -0,0 +1,150 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/github"
+)
+
+// tokenTransport adds a GitHub token to the requests, like the client of
+// the server does.
+type tokenTransport struct{}
+
+func (tokenTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.Header.Set("Authorization", "token secret")
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+// testDownloadServer serves the asset API, which answers with apiStatus or
+// redirects to the asset if it is http.StatusFound, and the asset, which
+// answers with assetStatus. It counts the requests to the API.
+func testDownloadServer(t *testing.T, apiStatus, assetStatus int, body string) (*github.Client, *int32) {
+	var requests int32
+	mux := http.NewServeMux()
+	mux.HandleFunc("/repos/owner/repo/releases/assets/1", func(w http.ResponseWriter, req *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		switch apiStatus {
+		case http.StatusOK:
+			w.Write([]byte(body))
+		case http.StatusFound:
+			http.Redirect(w, req, "/download/asset", apiStatus)
+		default:
+			http.Error(w, `{"message": "failed"}`, apiStatus)
+		}
+	})
+	mux.HandleFunc("/download/asset", func(w http.ResponseWriter, req *http.Request) {
+		// The redirect is to a signed url, which must not get the token.
+		if req.Header.Get("Authorization") != "" {
+			t.Error("the redirect got the GitHub token")
+		}
+		if assetStatus != http.StatusOK {
+			http.Error(w, "failed", assetStatus)
+			return
+		}
+		w.Write([]byte(body))
+	})
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+
+	client := github.NewClient(&http.Client{Transport: tokenTransport{}})
+	client.BaseURL, _ = url.Parse(srv.URL + "/")
+	return client, &requests
+}
+
+func testDownloadGlobals(t *testing.T) {
+	prevClient, prevTimeout := downloadClient, downloadTimeout
+	downloadClient, downloadTimeout = &http.Client{}, 5*time.Second
+	t.Cleanup(func() { downloadClient, downloadTimeout = prevClient, prevTimeout })
+}
+
+func TestDownloadAssetOnce(t *testing.T) {
+	testDownloadGlobals(t)
+
+	const sum = "abc  releases-linux-amd64\n"
+	testCases := []struct {
+		name        string
+		apiStatus   int
+		assetStatus int
+		body        string
+		err         string
+		retryable   bool
+	}{
+		{name: "asset", apiStatus: http.StatusOK, body: sum},
+		{name: "redirect", apiStatus: http.StatusFound, assetStatus: http.StatusOK, body: sum},
+		{name: "internal server error", apiStatus: http.StatusInternalServerError, err: "500", retryable: true},
+		{name: "bad gateway", apiStatus: http.StatusBadGateway, err: "502", retryable: true},
+		{name: "service unavailable", apiStatus: http.StatusServiceUnavailable, err: "503", retryable: true},
+		{name: "not found", apiStatus: http.StatusNotFound, err: "404"},
+		{name: "unauthorized", apiStatus: http.StatusUnauthorized, err: "401"},
+		{name: "unprocessable", apiStatus: http.StatusUnprocessableEntity, err: "422"},
+		{name: "redirect server error", apiStatus: http.StatusFound, assetStatus: http.StatusBadGateway, err: "getting the redirect failed: 502", retryable: true},
+		{name: "redirect not found", apiStatus: http.StatusFound, assetStatus: http.StatusNotFound, err: "getting the redirect failed: 404"},
+		{name: "redirect forbidden", apiStatus: http.StatusFound, assetStatus: http.StatusForbidden, err: "getting the redirect failed: 403"},
+		{name: "too large", apiStatus: http.StatusOK, body: strings.Repeat("a", checksumMaxSize+1), err: "asset is larger than"},
+	}
+
+	repo := &github.Repository{Name: github.String("repo"), Owner: &github.User{Login: github.String("owner")}}
+	for _, tc := range testCases {
+		client, _ := testDownloadServer(t, tc.apiStatus, tc.assetStatus, tc.body)
+		b, err := downloadAssetOnce(context.Background(), client, repo, 1)
+		if tc.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tc.err) {
+				t.Errorf("%s: got error %v, want it to contain %q", tc.name, err, tc.err)
+				continue
+			}
+			if _, ok := err.(*retryableError); ok != tc.retryable {
+				t.Errorf("%s: got retryable %v, want %v", tc.name, ok, tc.retryable)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tc.name, err)
+			continue
+		}
+		if string(b) != tc.body {
+			t.Errorf("%s: got %q, want %q", tc.name, b, tc.body)
+		}
+	}
+
+	// Connection errors are retried.
+	client, _ := testDownloadServer(t, http.StatusOK, 0, sum)
+	client.BaseURL, _ = url.Parse("http://127.0.0.1:1/")
+	if _, err := downloadAssetOnce(context.Background(), client, repo, 1); err == nil {
+		t.Error("connection refused: expected an error")
+	} else if _, ok := err.(*retryableError); !ok {
+		t.Errorf("connection refused: got %v, want a retryable error", err)
+	}
+}
+
+func TestDownloadAsset(t *testing.T) {
+	testDownloadGlobals(t)
+	repo := &github.Repository{Name: github.String("repo"), Owner: &github.User{Login: github.String("owner")}}
+
+	// Errors that are not retryable are returned right away.
+	client, requests := testDownloadServer(t, http.StatusNotFound, 0, "")
+	if _, err := downloadAsset(context.Background(), client, repo, 1); err == nil {
+		t.Error("not found: expected an error")
+	}
+	if n := atomic.LoadInt32(requests); n != 1 {
+		t.Errorf("not found: got %d requests, want 1", n)
+	}
+
+	// Retryable errors are tried until the context is done.
+	client, requests = testDownloadServer(t, http.StatusBadGateway, 0, "")
+	ctx, cancel := context.WithTimeout(context.Background(), 2*downloadBackoff)
+	defer cancel()
+	if _, err := downloadAsset(ctx, client, repo, 1); err == nil {
+		t.Error("bad gateway: expected an error")
+	}
+	if n := atomic.LoadInt32(requests); n < 2 || n >= downloadAttempts {
+		t.Errorf("bad gateway: got %d requests, want a retry but not all %d attempts", n, downloadAttempts)
+	}
+}
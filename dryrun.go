@@ -0,0 +1,81 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// bodyDiff is the change a release body update would make.
+type bodyDiff struct {
+	Repository string
+	Tag        string
+	Diff       string
+}
+
+// bodyDiffs collects the release body changes computed during a refresh
+// when running with --update-release-body=dry-run.
+type bodyDiffs struct {
+	mu sync.Mutex
+
+	// next is filled during a refresh and replaces current when it is done.
+	next    []bodyDiff
+	checked int
+
+	current        []bodyDiff
+	currentChecked int
+}
+
+var dryRunDiffs = &bodyDiffs{}
+
+// reset starts collecting the changes for a new refresh.
+func (d *bodyDiffs) reset() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.next = nil
+	d.checked = 0
+}
+
+// add records the change to a release body, diff is empty if nothing would
+// change.
+func (d *bodyDiffs) add(repo, tag, diff string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.checked++
+	if diff != "" {
+		d.next = append(d.next, bodyDiff{Repository: repo, Tag: tag, Diff: diff})
+	}
+}
+
+// publish makes the changes collected since the last reset the current
+// ones and returns their summary.
+func (d *bodyDiffs) publish() string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	sort.Slice(d.next, func(i, j int) bool {
+		if d.next[i].Repository == d.next[j].Repository {
+			return d.next[i].Tag < d.next[j].Tag
+		}
+		return d.next[i].Repository < d.next[j].Repository
+	})
+	d.current, d.currentChecked = d.next, d.checked
+	return d.summary()
+}
+
+func (d *bodyDiffs) summary() string {
+	return fmt.Sprintf("%d of %d release bodies would change", len(d.current), d.currentChecked)
+}
+
+// String returns the summary followed by the diffs of the last refresh.
+func (d *bodyDiffs) String() string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "%s.\n", d.summary())
+	for _, diff := range d.current {
+		fmt.Fprintf(&b, "\n# %s %s\n%s", diff.Repository, diff.Tag, diff.Diff)
+	}
+	return b.String()
+}
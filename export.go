@@ -0,0 +1,222 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const exportHelp = `Fetch the releases once and write the site to a directory.`
+
+func (cmd *exportCommand) Name() string      { return "export" }
+func (cmd *exportCommand) Args() string      { return "" }
+func (cmd *exportCommand) ShortHelp() string { return exportHelp }
+func (cmd *exportCommand) LongHelp() string  { return exportHelp }
+func (cmd *exportCommand) Hidden() bool      { return false }
+
+func (cmd *exportCommand) Register(fs *flag.FlagSet) {
+	fs.StringVar(&cmd.out, "out", "site", "directory to write the site to")
+}
+
+type exportCommand struct {
+	out string
+}
+
+func (cmd *exportCommand) Run(ctx context.Context, args []string) error {
+	client, affiliation, err := newClient(ctx)
+	if err != nil {
+		return err
+	}
+
+	releases, index, err := run(ctx, client, affiliation)
+	if err != nil {
+		return err
+	}
+	downloadStats.record(releases, time.Now())
+
+	files, err := renderSite(releases, index.Bytes())
+	if err != nil {
+		return err
+	}
+
+	for p, b := range files {
+		if err := writeFile(filepath.Join(cmd.out, p), b); err != nil {
+			return err
+		}
+	}
+	pruned, err := pruneExport(cmd.out, files)
+	if err != nil {
+		return err
+	}
+
+	logrus.Infof("Wrote %d files to %s, removed %d stale files", len(files), cmd.out, pruned)
+	return nil
+}
+
+// exportManifest lists the files written by the last export, so the next
+// export can remove the ones it no longer writes without touching other
+// files in the directory.
+const exportManifest = ".releases-export"
+
+// pruneExport removes the files of the previous export to dir that were not
+// written this time and records the files that were. It returns how many
+// files were removed.
+func pruneExport(dir string, files map[string][]byte) (int, error) {
+	pruned := 0
+	b, err := ioutil.ReadFile(filepath.Join(dir, exportManifest))
+	if err != nil && !os.IsNotExist(err) {
+		return 0, err
+	}
+	for _, p := range strings.Split(string(b), "\n") {
+		if p == "" {
+			continue
+		}
+		// Never remove anything outside of dir, even if the manifest was
+		// edited.
+		p = filepath.Clean(filepath.FromSlash(p))
+		if filepath.IsAbs(p) || p == ".." || strings.HasPrefix(p, ".."+string(filepath.Separator)) || files[p] != nil {
+			continue
+		}
+		if err := os.Remove(filepath.Join(dir, p)); err != nil && !os.IsNotExist(err) {
+			return pruned, err
+		}
+		pruned++
+
+		// Remove the directories that are empty now, like those of a
+		// repository that is gone.
+		for d := filepath.Dir(p); d != "."; d = filepath.Dir(d) {
+			if os.Remove(filepath.Join(dir, d)) != nil {
+				break
+			}
+		}
+	}
+
+	written := make([]string, 0, len(files))
+	for p := range files {
+		written = append(written, filepath.ToSlash(p))
+	}
+	sort.Strings(written)
+	return pruned, writeFile(filepath.Join(dir, exportManifest), []byte(strings.Join(written, "\n")+"\n"))
+}
+
+// renderSite returns the files of the static site keyed by their path,
+// laid out like the paths the server serves them at. The links in the pages
+// are made relative, so the site works from any directory on a plain file or
+// object storage server.
+func renderSite(releases []release, index []byte) (map[string][]byte, error) {
+	files := map[string][]byte{
+		"index.html": index,
+	}
+
+	a := newAnalytics(releases)
+	b, err := renderAnalytics(a)
+	if err != nil {
+		return nil, err
+	}
+	files[filepath.Join("analytics", "index.html")] = b
+
+	b, err = json.MarshalIndent(a, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	files[filepath.Join("api", "v1", "analytics.json")] = b
+	files[filepath.Join("api", "v1", "analytics.csv")] = analyticsCSV(a)
+
+	b, err = releasesJSON(releases)
+	if err != nil {
+		return nil, err
+	}
+	files[filepath.Join("api", "v1", "releases.json")] = b
+
+	b, err = json.MarshalIndent(lintReports(releases), "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	files[filepath.Join("api", "v1", "lint.json")] = b
+
+	b, err = releasesFeed("Latest Releases", "", releases)
+	if err != nil {
+		return nil, err
+	}
+	files["feed.atom"] = b
+
+	now := time.Now()
+	for _, r := range releases {
+		if r.Release == nil {
+			continue
+		}
+		owner, name := r.Repository.GetOwner().GetLogin(), r.Repository.GetName()
+
+		rs, err := downloadStats.stats(r.Repository.GetFullName(), now)
+		if err != nil {
+			return nil, fmt.Errorf("getting download stats of %s failed: %v", r.Repository.GetFullName(), err)
+		}
+
+		for p, fn := range map[string]func(release) ([]byte, error){
+			filepath.Join(owner, name, "index.html"):                    renderRepo,
+			filepath.Join("api", "v1", "releases", owner, name+".json"): releaseJSON,
+			filepath.Join("feeds", owner, name+".atom"):                 repoFeed,
+			filepath.Join("badges", "release", owner, name+".svg"):      releaseBadge,
+			filepath.Join("badges", "downloads", owner, name+".svg"):    downloadsBadge,
+			filepath.Join("stats", owner, name, "index.html"): func(r release) ([]byte, error) {
+				return renderStats(r, rs)
+			},
+			filepath.Join("api", "v1", "stats", owner, name+".json"): func(release) ([]byte, error) {
+				return json.MarshalIndent(rs, "", "  ")
+			},
+		} {
+			b, err := fn(r)
+			if err != nil {
+				return nil, fmt.Errorf("rendering %s failed: %v", p, err)
+			}
+			files[p] = b
+		}
+	}
+
+	for p, b := range files {
+		if strings.HasSuffix(p, ".html") {
+			files[p] = relativeLinks(p, b, files)
+		}
+	}
+
+	return files, nil
+}
+
+// siteLinkRegexp matches the links to paths on the server in a page.
+var siteLinkRegexp = regexp.MustCompile(`(href|src)="(/[^"#?]*)"`)
+
+// relativeLinks rewrites the links to paths on the server in the page p to
+// the files of the site relative to p. Links to directories point at their
+// index.html and links to the JSON API at the .json file. Links to files
+// that are not in the site are left alone.
+func relativeLinks(p string, b []byte, files map[string][]byte) []byte {
+	return siteLinkRegexp.ReplaceAllFunc(b, func(m []byte) []byte {
+		sub := siteLinkRegexp.FindSubmatch(m)
+		target := filepath.FromSlash(strings.TrimPrefix(string(sub[2]), "/"))
+		for _, candidate := range []string{
+			target,
+			filepath.Join(target, "index.html"),
+			target + ".json",
+		} {
+			if files[candidate] == nil {
+				continue
+			}
+			rel, err := filepath.Rel(filepath.Dir(p), candidate)
+			if err != nil {
+				break
+			}
+			return []byte(fmt.Sprintf(`%s="%s"`, sub[1], filepath.ToSlash(rel)))
+		}
+		return m
+	})
+}
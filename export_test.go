@@ -0,0 +1,148 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestRelativeLinks(t *testing.T) {
+	files := map[string][]byte{
+		"index.html":                            nil,
+		"feed.atom":                             nil,
+		"analytics/index.html":                  nil,
+		"genuinetools/img/index.html":           nil,
+		"api/v1/releases/genuinetools/img.json": nil,
+		"badges/release/genuinetools/img.svg":   nil,
+		"stats/genuinetools/img/index.html":     nil,
+		"api/v1/stats/genuinetools/img.json":    nil,
+		"feeds/genuinetools/img.atom":           nil,
+	}
+	for p := range files {
+		files[p] = []byte{}
+	}
+
+	testCases := []struct {
+		page string
+		in   string
+		want string
+	}{
+		{"index.html", `<a href="/">`, `<a href="index.html">`},
+		{"index.html", `<a href="/genuinetools/img">`, `<a href="genuinetools/img/index.html">`},
+		{"index.html", `<link href="/feed.atom">`, `<link href="feed.atom">`},
+		{"genuinetools/img/index.html", `<a href="/">`, `<a href="../../index.html">`},
+		{"genuinetools/img/index.html", `<img src="/badges/release/genuinetools/img.svg">`, `<img src="../../badges/release/genuinetools/img.svg">`},
+		{"genuinetools/img/index.html", `<a href="/api/v1/releases/genuinetools/img">`, `<a href="../../api/v1/releases/genuinetools/img.json">`},
+		{"stats/genuinetools/img/index.html", `<a href="/genuinetools/img">`, `<a href="../../../genuinetools/img/index.html">`},
+		// Links to files that are not in the site, with a query or a
+		// fragment, or to other sites are left alone.
+		{"index.html", `<a href="/winget/genuinetools/img.yaml">`, `<a href="/winget/genuinetools/img.yaml">`},
+		{"index.html", `<a href="/genuinetools/img#install">`, `<a href="/genuinetools/img#install">`},
+		{"index.html", `<a href="/genuinetools/img?tab=1">`, `<a href="/genuinetools/img?tab=1">`},
+		{"index.html", `<a href="https://github.com/genuinetools/img">`, `<a href="https://github.com/genuinetools/img">`},
+		{"index.html", `<a href="/a"><a href="/analytics">`, `<a href="/a"><a href="analytics/index.html">`},
+	}
+
+	for _, tc := range testCases {
+		page := filepath.FromSlash(tc.page)
+		if got := string(relativeLinks(page, []byte(tc.in), fromSlash(files))); got != tc.want {
+			t.Errorf("%s %s: got %s, want %s", tc.page, tc.in, got, tc.want)
+		}
+	}
+}
+
+// fromSlash converts the slash separated paths of the files to the paths of
+// the os.
+func fromSlash(files map[string][]byte) map[string][]byte {
+	result := map[string][]byte{}
+	for p, b := range files {
+		result[filepath.FromSlash(p)] = b
+	}
+	return result
+}
+
+// listFiles returns the files in dir, slash separated and sorted.
+func listFiles(t *testing.T, dir string) []string {
+	var files []string
+	err := filepath.Walk(dir, func(p string, fi os.FileInfo, err error) error {
+		if err != nil || fi.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(dir, p)
+		files = append(files, filepath.ToSlash(rel))
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(files)
+	return files
+}
+
+func TestPruneExport(t *testing.T) {
+	root := t.TempDir()
+	dir := filepath.Join(root, "site")
+
+	export := func(paths ...string) int {
+		files := map[string][]byte{}
+		for _, p := range paths {
+			files[filepath.FromSlash(p)] = []byte(p)
+			if err := writeFile(filepath.Join(dir, filepath.FromSlash(p)), []byte(p)); err != nil {
+				t.Fatal(err)
+			}
+		}
+		pruned, err := pruneExport(dir, files)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return pruned
+	}
+
+	export("index.html", "genuinetools/img/index.html", "genuinetools/reg/index.html", "feeds/genuinetools/reg.atom")
+
+	// Files that were not exported are kept, in the directory and next
+	// to it.
+	for _, p := range []string{"site/CNAME", "site/genuinetools/notes.txt", "outside.txt"} {
+		if err := writeFile(filepath.Join(root, filepath.FromSlash(p)), []byte(p)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if pruned := export("index.html", "genuinetools/img/index.html"); pruned != 2 {
+		t.Errorf("got %d pruned files, want 2", pruned)
+	}
+	want := []string{
+		".releases-export",
+		"CNAME",
+		"genuinetools/img/index.html",
+		"genuinetools/notes.txt",
+		"index.html",
+	}
+	if got := listFiles(t, dir); strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("got files %v, want %v", got, want)
+	}
+	// The directories of the removed repository are gone.
+	for _, d := range []string{"genuinetools/reg", "feeds"} {
+		if _, err := os.Stat(filepath.Join(dir, filepath.FromSlash(d))); !os.IsNotExist(err) {
+			t.Errorf("%s should have been removed: %v", d, err)
+		}
+	}
+
+	// Even an edited manifest never removes files outside the directory.
+	manifest := "index.html\n../outside.txt\n" + filepath.Join(root, "outside.txt") + "\ngenuinetools/../../outside.txt\n"
+	if err := ioutil.WriteFile(filepath.Join(dir, exportManifest), []byte(manifest), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if pruned := export("index.html"); pruned != 0 {
+		t.Errorf("got %d pruned files, want 0", pruned)
+	}
+	if _, err := os.Stat(filepath.Join(root, "outside.txt")); err != nil {
+		t.Errorf("the file outside of the export was removed: %v", err)
+	}
+	if b, _ := ioutil.ReadFile(filepath.Join(dir, exportManifest)); string(b) != "index.html\n" {
+		t.Errorf("got manifest %q, want %q", b, "index.html\n")
+	}
+}
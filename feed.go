@@ -0,0 +1,92 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// atomFeed is an Atom feed of releases.
+// See: https://tools.ietf.org/html/rfc4287
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	ID      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Link    *atomLink   `xml:"link,omitempty"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+}
+
+type atomEntry struct {
+	ID      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Link    atomLink    `xml:"link"`
+	Author  atomAuthor  `xml:"author"`
+	Content atomContent `xml:"content"`
+}
+
+type atomAuthor struct {
+	Name string `xml:"name"`
+}
+
+type atomContent struct {
+	Type string `xml:"type,attr"`
+	Body string `xml:",chardata"`
+}
+
+// releasesFeed returns an Atom feed of the latest releases, newest first.
+func releasesFeed(title, link string, releases []release) ([]byte, error) {
+	var rls []release
+	for _, r := range releases {
+		if r.Release != nil {
+			rls = append(rls, r)
+		}
+	}
+	sort.Slice(rls, func(i, j int) bool {
+		return rls[i].Release.GetPublishedAt().After(rls[j].Release.GetPublishedAt().Time)
+	})
+
+	f := atomFeed{
+		ID:      link,
+		Title:   title,
+		Updated: time.Time{}.Format(time.RFC3339),
+	}
+	if link != "" {
+		f.Link = &atomLink{Href: link}
+	} else {
+		// The feed of an exported site does not know where it is hosted.
+		f.ID = "urn:genuinetools:releases"
+	}
+	for _, r := range rls {
+		published := r.Release.GetPublishedAt().UTC()
+		if f.Updated < published.Format(time.RFC3339) {
+			f.Updated = published.Format(time.RFC3339)
+		}
+
+		f.Entries = append(f.Entries, atomEntry{
+			ID:      r.Release.GetHTMLURL(),
+			Title:   fmt.Sprintf("%s %s", r.Repository.GetFullName(), r.Release.GetTagName()),
+			Updated: published.Format(time.RFC3339),
+			Link:    atomLink{Href: r.Release.GetHTMLURL()},
+			Author:  atomAuthor{Name: r.Release.GetAuthor().GetLogin()},
+			Content: atomContent{Type: "text", Body: r.Release.GetBody()},
+		})
+	}
+
+	b, err := xml.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), b...), nil
+}
+
+// repoFeed returns an Atom feed of the latest release of a repository.
+func repoFeed(r release) ([]byte, error) {
+	return releasesFeed(r.Repository.GetFullName()+" releases", r.Repository.GetHTMLURL()+"/releases", []release{r})
+}
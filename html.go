@@ -6,80 +6,14 @@ const (
 	<head>
 		<meta charset="utf-8">
 		<title>GitHub Releases</title>
-		<style>
-			html {
-				display: block;
-			}
-			body{
-				font-family: Consolas, Inconsolata, monospace;
-				display: block;
-				margin: 0;
-				font-size: 1rem;
-				font-weight: 400;
-				line-height: 1.5;
-				color: #212529;
-				text-align: left;
-				background-color: #fff;
-			}
-			@media (min-width: 1200px) .container {
-				max-width: 1140px;
-			}
-			@media (min-width: 992px) .container {
-				max-width: 960px;
-			}
-			@media (min-width: 768px) .container {
-				max-width: 720px;
-			}
-			@media (min-width: 576px) .container {
-				max-width: 540px;
-			}
-			.container {
-				max-width: 100%;
-				padding: 1rem;
-				margin: auto;
-			}
-			table {
-				background-color: transparent;
-				border-color: transparent;
-				border-collapse: collapse;
-				border-spacing: 2px;
-				border-color: grey;
-				text-align: inherit;
-				font-size: .75rem;
-				margin-bottom: 20px;
-			}
-			thead {
-				display: table-header-group;
-				vertical-align: middle;
-				border-color: inherit;
-			}
-			tr {
-				display: table-row;
-				vertical-align: inherit;
-				border-color: inherit;
-			}
-			thead th {
-				vertical-align: bottom;
-				border-bottom: 2px solid #dee2e6;
-			}
-			td, th {
-				padding: .75rem;
-				vertical-align: top;
-				border-top: 1px solid #dee2e6;
-				display: table-cell;
-			}
-			tbody {
-				display: table-row-group;
-				vertical-align: middle;
-				border-color: inherit;
-			}
-		</style>
+` + style + `
 	</head>
 	<body>
 		<div class="container">
 			<h1>Latest Releases</h1>
 			<p>This only shows the hashes and download links for linux amd64. For other archs click the tag
 			to view the release page.</p>
+			<p><small>See the <a href="/analytics">downloads by platform</a>.</small></p>
 			<p><small>If you wish to modify this page, the repo is: <a href="https://github.com/genuinetools/releases" target="_blank">genuinetools/releases</a></small></p>
 
 			<table>
@@ -91,6 +25,7 @@ const (
 						<th>sha256</th>
 						<th>released</th>
 						<th>download count</th>
+						<th>warnings</th>
 					</tr>
 				</thead>
 				<tbody>
@@ -102,6 +37,7 @@ const (
 						<td><code>{{.BinarySHA256}}</code></td>
 						<td>{{.BinarySince}} ago</td>
 						<td><bold>{{.BinaryDownloadCount}}</bold></td>
+						<td>{{range .Warnings}}<div><small>{{.Message}}</small></div>{{end}}</td>
 					</tr>
 				{{end}}
 				</tbody>
@@ -172,4 +108,219 @@ const (
 
 	</body>
 </html>`
+
+	repoTmpl = `<!DOCTYPE html>
+<html lang="en-us">
+	<head>
+		<meta charset="utf-8">
+		<title>{{.Repository.FullName}} - GitHub Releases</title>
+` + style + `
+	</head>
+	<body>
+		<div class="container">
+			<h1><a href="{{.Repository.HTMLURL}}" target="_blank">{{.Repository.FullName}}</a></h1>
+			{{with .Repository.Description}}<p>{{.}}</p>{{end}}
+			<p>Latest release: <a href="{{.Release.HTMLURL}}" target="_blank">{{.Release.TagName}}</a>, released {{.BinarySince}} ago, downloaded <a href="/stats/{{.Repository.FullName}}"><bold>{{.BinaryDownloadCount}}</bold> times</a>.</p>
+			<p><small><a href="/">Back to all releases</a></small></p>
+
+			<table>
+				<thead>
+					<tr>
+						<th>os</th>
+						<th>arch</th>
+						<th>download</th>
+						<th>sha256</th>
+					</tr>
+				</thead>
+				<tbody>
+				{{range $os, $v := .Platforms}}
+				{{range $arch, $r := $v}}
+					<tr>
+						<td>{{$os}}</td>
+						<td>{{$arch}}</td>
+						<td><a href="{{$r.BinaryURL}}" target="_blank"><code>{{$r.BinaryName}}</code></a></td>
+						<td><code>{{$r.BinarySHA256}}</code></td>
+					</tr>
+				{{end}}
+				{{end}}
+				</tbody>
+			</table>
+		</div>
+	</body>
+</html>`
+
+	statsTmpl = `<!DOCTYPE html>
+<html lang="en-us">
+	<head>
+		<meta charset="utf-8">
+		<title>{{.Release.Repository.FullName}} downloads - GitHub Releases</title>
+` + style + `
+	</head>
+	<body>
+		<div class="container">
+			<h1><a href="/{{.Release.Repository.FullName}}">{{.Release.Repository.FullName}}</a> downloads</h1>
+			<p>{{.Release.BinaryDownloadCount}} downloads in total. The data is also available as <a href="/api/v1/stats/{{.Release.Repository.FullName}}">JSON</a>.</p>
+
+			<h2>Daily</h2>
+			{{.Daily}}
+
+			<h2>Weekly</h2>
+			{{.Weekly}}
+		</div>
+	</body>
+</html>`
+
+	analyticsTmpl = `<!DOCTYPE html>
+<html lang="en-us">
+	<head>
+		<meta charset="utf-8">
+		<title>Downloads by platform - GitHub Releases</title>
+` + style + `
+	</head>
+	<body>
+		<div class="container">
+			<h1>Downloads by platform</h1>
+			<p>{{.Total}} binary downloads across all releases of all repositories. The data is also available as
+			<a href="/api/v1/analytics.json">JSON</a> and <a href="/api/v1/analytics.csv">CSV</a>.</p>
+			<p><small><a href="/">Back to all releases</a></small></p>
+
+			<h2>By platform</h2>
+			<table>
+				<thead>
+					<tr>
+						<th>os</th>
+						<th>arch</th>
+						<th>downloads</th>
+						<th>share</th>
+						<th>repositories</th>
+					</tr>
+				</thead>
+				<tbody>
+				{{range .Platforms}}
+					<tr>
+						<td>{{.OS}}</td>
+						<td>{{.Arch}}</td>
+						<td><bold>{{.Downloads}}</bold></td>
+						<td>{{printf "%.2f" .Percent}}%</td>
+						<td>{{.Repositories}}</td>
+					</tr>
+				{{end}}
+				</tbody>
+			</table>
+
+			<h2>By os</h2>
+			<table>
+				<thead>
+					<tr>
+						<th>os</th>
+						<th>downloads</th>
+						<th>share</th>
+						<th>repositories</th>
+					</tr>
+				</thead>
+				<tbody>
+				{{range .OS}}
+					<tr>
+						<td>{{.OS}}</td>
+						<td><bold>{{.Downloads}}</bold></td>
+						<td>{{printf "%.2f" .Percent}}%</td>
+						<td>{{.Repositories}}</td>
+					</tr>
+				{{end}}
+				</tbody>
+			</table>
+
+			<h2>By release</h2>
+			<table>
+				<thead>
+					<tr>
+						<th>Project</th>
+						<th>Release</th>
+						<th>binaries</th>
+						<th>downloads</th>
+					</tr>
+				</thead>
+				<tbody>
+				{{range .Releases}}
+					<tr>
+						<td>{{.Repository}}</td>
+						<td>{{.Tag}}</td>
+						<td>{{.Binaries}}</td>
+						<td><bold>{{.Downloads}}</bold></td>
+					</tr>
+				{{end}}
+				</tbody>
+			</table>
+		</div>
+	</body>
+</html>`
+
+	style = `		<style>
+			html {
+				display: block;
+			}
+			body{
+				font-family: Consolas, Inconsolata, monospace;
+				display: block;
+				margin: 0;
+				font-size: 1rem;
+				font-weight: 400;
+				line-height: 1.5;
+				color: #212529;
+				text-align: left;
+				background-color: #fff;
+			}
+			@media (min-width: 1200px) .container {
+				max-width: 1140px;
+			}
+			@media (min-width: 992px) .container {
+				max-width: 960px;
+			}
+			@media (min-width: 768px) .container {
+				max-width: 720px;
+			}
+			@media (min-width: 576px) .container {
+				max-width: 540px;
+			}
+			.container {
+				max-width: 100%;
+				padding: 1rem;
+				margin: auto;
+			}
+			table {
+				background-color: transparent;
+				border-color: transparent;
+				border-collapse: collapse;
+				border-spacing: 2px;
+				border-color: grey;
+				text-align: inherit;
+				font-size: .75rem;
+				margin-bottom: 20px;
+			}
+			thead {
+				display: table-header-group;
+				vertical-align: middle;
+				border-color: inherit;
+			}
+			tr {
+				display: table-row;
+				vertical-align: inherit;
+				border-color: inherit;
+			}
+			thead th {
+				vertical-align: bottom;
+				border-bottom: 2px solid #dee2e6;
+			}
+			td, th {
+				padding: .75rem;
+				vertical-align: top;
+				border-top: 1px solid #dee2e6;
+				display: table-cell;
+			}
+			tbody {
+				display: table-row-group;
+				vertical-align: middle;
+				border-color: inherit;
+			}
+		</style>`
 )
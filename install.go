@@ -0,0 +1,261 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/google/go-github/github"
+	"github.com/sirupsen/logrus"
+)
+
+const installHelp = `Download, verify and install the binary of a release for this platform.`
+
+func (cmd *installCommand) Name() string      { return "install" }
+func (cmd *installCommand) Args() string      { return "<owner/repo[@tag]>" }
+func (cmd *installCommand) ShortHelp() string { return installHelp }
+func (cmd *installCommand) LongHelp() string  { return installHelp }
+func (cmd *installCommand) Hidden() bool      { return false }
+
+func (cmd *installCommand) Register(fs *flag.FlagSet) {
+	fs.StringVar(&cmd.to, "to", "/usr/local/bin", "directory to install the binary to")
+	fs.StringVar(&cmd.server, "server", "", "URL of a releases server to resolve the binary with instead of GitHub")
+	fs.StringVar(&cmd.platform, "platform", runtime.GOOS+"-"+runtime.GOARCH, "platform of the binary as os-arch")
+}
+
+type installCommand struct {
+	to       string
+	server   string
+	platform string
+}
+
+const (
+	// installTimeout is how long resolving a binary with a releases server
+	// may take.
+	installTimeout = 30 * time.Second
+	// installDownloadTimeout is how long downloading a binary may take.
+	installDownloadTimeout = 10 * time.Minute
+)
+
+// installClient gives up on servers that do not respond, rather than
+// hanging forever like http.DefaultClient. The timeouts of the requests
+// are set by their callers.
+var installClient = &http.Client{
+	Transport: &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout: installTimeout,
+		}).DialContext,
+		TLSHandshakeTimeout:   installTimeout,
+		ResponseHeaderTimeout: installTimeout,
+	},
+}
+
+// installAsset is the binary to install.
+type installAsset struct {
+	Name   string
+	Tag    string
+	URL    string
+	SHA256 string
+}
+
+func (cmd *installCommand) Run(ctx context.Context, args []string) error {
+	if len(args) != 1 {
+		return errors.New("must pass a repository as owner/repo[@tag]")
+	}
+
+	name, tag := args[0], ""
+	if i := strings.Index(name, "@"); i >= 0 {
+		name, tag = name[:i], name[i+1:]
+	}
+	s := strings.SplitN(name, "/", 2)
+	if len(s) != 2 || s[0] == "" || s[1] == "" {
+		return fmt.Errorf("repository %q must be of the form owner/repo[@tag]", args[0])
+	}
+	owner, repo := s[0], s[1]
+
+	p := strings.SplitN(cmd.platform, "-", 2)
+	if len(p) != 2 {
+		return fmt.Errorf("platform %q must be of the form os-arch", cmd.platform)
+	}
+
+	var (
+		a   *installAsset
+		err error
+	)
+	if cmd.server != "" {
+		a, err = resolveServerAsset(ctx, cmd.server, owner, repo, tag, p[0], p[1])
+	} else {
+		var client *github.Client
+		client, _, err = newClient(ctx)
+		if err != nil {
+			return err
+		}
+		a, err = resolveGitHubAsset(ctx, client, owner, repo, tag, p[0], p[1])
+	}
+	if err != nil {
+		return err
+	}
+	// Checksum files are often the output of sha256sum, "<sum>  <name>\n".
+	sum := strings.Fields(a.SHA256)
+	if len(sum) < 1 {
+		return fmt.Errorf("%s %s has no sha256 to verify the download with", name, a.Name)
+	}
+	a.SHA256 = sum[0]
+
+	dest := filepath.Join(cmd.to, repo)
+	if p[0] == "windows" {
+		dest += ".exe"
+	}
+
+	logrus.Infof("Installing %s %s to %s...", name, a.Tag, dest)
+	if err := installBinary(ctx, a, dest); err != nil {
+		return err
+	}
+
+	fmt.Printf("%s %s installed to %s\n", name, a.Tag, dest)
+	return nil
+}
+
+// resolveGitHubAsset finds the binary and its sha256 for the platform in
+// the release with tag, or the latest release if tag is empty.
+func resolveGitHubAsset(ctx context.Context, client *github.Client, owner, repo, tag, osn, arch string) (*installAsset, error) {
+	var (
+		r   *github.RepositoryRelease
+		err error
+	)
+	if tag == "" {
+		r, _, err = client.Repositories.GetLatestRelease(ctx, owner, repo)
+	} else {
+		r, _, err = client.Repositories.GetReleaseByTag(ctx, owner, repo, tag)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("getting release of %s/%s failed: %v", owner, repo, err)
+	}
+
+	m := newBinaryMatcher(settingsFor(owner+"/"+repo).binary, repo)
+	a := &installAsset{
+		Name: m.name(osn, arch),
+		Tag:  r.GetTagName(),
+	}
+	gr := &github.Repository{
+		Name:  &repo,
+		Owner: &github.User{Login: &owner},
+	}
+	for _, asset := range r.Assets {
+		switch asset.GetName() {
+		case a.Name:
+			a.URL = asset.GetBrowserDownloadURL()
+		case a.Name + ".sha256":
+			a.SHA256, err = getReleaseAssetContent(ctx, client, gr, &asset)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	if a.URL == "" {
+		return nil, fmt.Errorf("%s/%s %s has no binary for %s-%s", owner, repo, a.Tag, osn, arch)
+	}
+
+	return a, nil
+}
+
+// resolveServerAsset finds the binary and its sha256 for the platform
+// using the API of a releases server. The server only knows about the
+// latest release.
+func resolveServerAsset(ctx context.Context, server, owner, repo, tag, osn, arch string) (*installAsset, error) {
+	u := strings.TrimSuffix(server, "/") + "/api/v1/releases/" + owner + "/" + repo + ".json"
+	req, err := http.NewRequest(http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, installTimeout)
+	defer cancel()
+	resp, err := installClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("getting %s failed: %v", u, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("getting %s failed: %s", u, resp.Status)
+	}
+
+	var ar apiRelease
+	if err := json.NewDecoder(resp.Body).Decode(&ar); err != nil {
+		return nil, fmt.Errorf("decoding %s failed: %v", u, err)
+	}
+	if tag != "" && tag != ar.Tag {
+		return nil, fmt.Errorf("%s only knows about the latest release %s of %s/%s, not %s", server, ar.Tag, owner, repo, tag)
+	}
+
+	b, ok := ar.Platforms[osn][arch]
+	if !ok {
+		return nil, fmt.Errorf("%s/%s %s has no binary for %s-%s", owner, repo, ar.Tag, osn, arch)
+	}
+
+	return &installAsset{
+		Name:   b.Name,
+		Tag:    ar.Tag,
+		URL:    b.URL,
+		SHA256: b.SHA256,
+	}, nil
+}
+
+// installBinary downloads the binary to a temporary file next to dest,
+// verifies its sha256 and then renames it to dest, so dest is never left
+// half written.
+func installBinary(ctx context.Context, a *installAsset, dest string) error {
+	req, err := http.NewRequest(http.MethodGet, a.URL, nil)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, installDownloadTimeout)
+	defer cancel()
+	resp, err := installClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return fmt.Errorf("downloading %s failed: %v", a.URL, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("downloading %s failed: %s", a.URL, resp.Status)
+	}
+
+	f, err := ioutil.TempFile(filepath.Dir(dest), "."+filepath.Base(dest))
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	h := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(f, h), resp.Body); err != nil {
+		f.Close()
+		return fmt.Errorf("downloading %s failed: %v", a.URL, err)
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	if sum := hex.EncodeToString(h.Sum(nil)); !strings.EqualFold(sum, a.SHA256) {
+		return fmt.Errorf("sha256 of %s is %s, expected %s", a.Name, sum, a.SHA256)
+	}
+
+	if err := os.Chmod(f.Name(), 0755); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), dest)
+}
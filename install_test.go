@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestInstallServer(t *testing.T) {
+	binary := []byte("#!/bin/sh\necho hello\n")
+	h := sha256.Sum256(binary)
+	sum := hex.EncodeToString(h[:])
+
+	var ar apiRelease
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v1/releases/owner/img.json", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(ar)
+	})
+	mux.HandleFunc("/dl/img-linux-amd64", func(w http.ResponseWriter, r *http.Request) {
+		w.Write(binary)
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	asset := func(sha string) map[string]map[string]apiAsset {
+		return map[string]map[string]apiAsset{
+			"linux": {
+				"amd64": {Name: "img-linux-amd64", URL: srv.URL + "/dl/img-linux-amd64", SHA256: sha},
+			},
+		}
+	}
+
+	testCases := []struct {
+		name      string
+		repo      string
+		platform  string
+		platforms map[string]map[string]apiAsset
+		err       string
+	}{
+		{
+			name:      "installed",
+			repo:      "owner/img",
+			platform:  "linux-amd64",
+			platforms: asset(sum),
+		},
+		{
+			name:      "sha256sum output",
+			repo:      "owner/img@v1.0.0",
+			platform:  "linux-amd64",
+			platforms: asset(strings.ToUpper(sum) + "  img-linux-amd64\n"),
+		},
+		{
+			name:      "checksum mismatch",
+			repo:      "owner/img",
+			platform:  "linux-amd64",
+			platforms: asset(strings.Repeat("0", 64)),
+			err:       "sha256 of img-linux-amd64 is " + sum,
+		},
+		{
+			name:      "no checksum",
+			repo:      "owner/img",
+			platform:  "linux-amd64",
+			platforms: asset(""),
+			err:       "has no sha256",
+		},
+		{
+			name:      "missing platform",
+			repo:      "owner/img",
+			platform:  "darwin-arm64",
+			platforms: asset(sum),
+			err:       "has no binary for darwin-arm64",
+		},
+		{
+			name:      "not the latest tag",
+			repo:      "owner/img@v0.9.0",
+			platform:  "linux-amd64",
+			platforms: asset(sum),
+			err:       "only knows about the latest release v1.0.0",
+		},
+		{
+			name:      "unknown repository",
+			repo:      "owner/other",
+			platform:  "linux-amd64",
+			platforms: asset(sum),
+			err:       "404 Not Found",
+		},
+	}
+
+	for _, tc := range testCases {
+		ar = apiRelease{Repository: "owner/img", Tag: "v1.0.0", Platforms: tc.platforms}
+		dir, err := ioutil.TempDir("", "install")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(dir)
+
+		cmd := &installCommand{to: dir, server: srv.URL, platform: tc.platform}
+		err = cmd.Run(context.Background(), []string{tc.repo})
+
+		// A failed install must not leave the binary or its temporary
+		// file behind.
+		files, lerr := ioutil.ReadDir(dir)
+		if lerr != nil {
+			t.Fatal(lerr)
+		}
+		if tc.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tc.err) {
+				t.Errorf("%s: got error %v, want it to contain %q", tc.name, err, tc.err)
+			}
+			if len(files) != 0 {
+				t.Errorf("%s: got %d files left in %s, want none", tc.name, len(files), dir)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tc.name, err)
+			continue
+		}
+		if len(files) != 1 || files[0].Name() != "img" {
+			t.Errorf("%s: got %d files in %s, want only img", tc.name, len(files), dir)
+			continue
+		}
+		b, err := ioutil.ReadFile(filepath.Join(dir, "img"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != string(binary) {
+			t.Errorf("%s: got binary %q, want %q", tc.name, b, binary)
+		}
+		if mode := files[0].Mode().Perm(); mode != 0755 {
+			t.Errorf("%s: got mode %v, want 0755", tc.name, mode)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"runtime"
+	"strings"
+)
+
+const latestHelp = `Print a field of the latest binary of a repository for a platform.`
+
+func (cmd *latestCommand) Name() string      { return "latest" }
+func (cmd *latestCommand) Args() string      { return "<owner/repo>" }
+func (cmd *latestCommand) ShortHelp() string { return latestHelp }
+func (cmd *latestCommand) LongHelp() string  { return latestHelp }
+func (cmd *latestCommand) Hidden() bool      { return false }
+
+func (cmd *latestCommand) Register(fs *flag.FlagSet) {
+	fs.StringVar(&cmd.platform, "platform", runtime.GOOS+"-"+runtime.GOARCH, "platform of the binary as os-arch")
+	fs.StringVar(&cmd.field, "field", "url", "field to print (url, sha256, name or tag)")
+}
+
+type latestCommand struct {
+	platform string
+	field    string
+}
+
+func (cmd *latestCommand) Run(ctx context.Context, args []string) error {
+	if len(args) != 1 {
+		return errors.New("must pass a repository as owner/repo")
+	}
+
+	p := strings.SplitN(cmd.platform, "-", 2)
+	if len(p) != 2 {
+		return fmt.Errorf("platform %q must be of the form os-arch", cmd.platform)
+	}
+
+	client, _, err := newClient(ctx)
+	if err != nil {
+		return err
+	}
+
+	r, err := getRelease(ctx, client, args[0])
+	if err != nil {
+		return err
+	}
+
+	b, ok := r.Platforms[p[0]][p[1]]
+	if !ok || b.BinaryURL == "" {
+		return fmt.Errorf("%s %s has no binary for %s", args[0], r.Release.GetTagName(), cmd.platform)
+	}
+
+	switch cmd.field {
+	case "url":
+		fmt.Println(b.BinaryURL)
+	case "sha256":
+		if b.BinarySHA256 == "" {
+			return fmt.Errorf("%s has no sha256", b.BinaryName)
+		}
+		fmt.Println(b.BinarySHA256)
+	case "name":
+		fmt.Println(b.BinaryName)
+	case "tag":
+		fmt.Println(r.Release.GetTagName())
+	default:
+		return fmt.Errorf("unknown field %q, must be url, sha256, name or tag", cmd.field)
+	}
+	return nil
+}
@@ -0,0 +1,214 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	_ "embed"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	units "github.com/docker/go-units"
+)
+
+const (
+	lintMissingBinary    = "missing-binary"
+	lintMissingChecksum  = "missing-checksum"
+	lintOrphanedChecksum = "orphaned-checksum"
+	lintEmptyAsset       = "empty-asset"
+	lintStaleRelease     = "stale-release"
+)
+
+// goosarch holds the platforms releases itself is built for, one os/arch
+// per line. They are expected unless --platforms or the config file say
+// otherwise.
+//
+//go:embed .goosarch
+var goosarch string
+
+// lintWarning is a problem found with the latest release of a repository.
+type lintWarning struct {
+	Check   string `json:"check"`
+	Message string `json:"message"`
+}
+
+// lintReport holds the warnings for the latest release of a repository.
+type lintReport struct {
+	Repository string        `json:"repository"`
+	Tag        string        `json:"tag"`
+	Warnings   []lintWarning `json:"warnings"`
+}
+
+// loadPlatforms reads the expected platforms from a file in the .goosarch
+// format, one os/arch per line. If p is empty the platforms releases itself
+// is built for are returned.
+func loadPlatforms(p string) ([]string, error) {
+	if p == "" {
+		return parsePlatforms(".goosarch", strings.NewReader(goosarch))
+	}
+
+	f, err := os.Open(p)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return parsePlatforms(p, f)
+}
+
+// parsePlatforms parses the platforms in the .goosarch format from r, name
+// is used in errors.
+func parsePlatforms(name string, r io.Reader) ([]string, error) {
+	var platforms []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if len(strings.SplitN(line, "/", 2)) != 2 {
+			return nil, fmt.Errorf("%s: %q is not of the form os/arch", name, line)
+		}
+		platforms = append(platforms, line)
+	}
+	return platforms, scanner.Err()
+}
+
+// lintRelease checks the assets of the latest release against the expected
+// platforms of the repository.
+func lintRelease(rl release, s repoSettings) []lintWarning {
+	if rl.Release == nil {
+		return nil
+	}
+
+	var (
+		warnings  []lintWarning
+		tag       = rl.Release.GetTagName()
+		names     = map[string]bool{}
+		hasBinary = false
+	)
+	for _, a := range rl.Assets {
+		if a.Tag != tag {
+			continue
+		}
+		names[a.Name] = true
+		if a.OS != "" {
+			hasBinary = true
+		}
+		if a.Size == 0 {
+			warnings = append(warnings, lintWarning{lintEmptyAsset, fmt.Sprintf("%s is empty", a.Name)})
+		}
+	}
+
+	// Only check for missing platforms if the release has binaries at all.
+	if hasBinary {
+		m := newBinaryMatcher(s.binary, rl.Repository.GetName())
+		for _, p := range s.platforms {
+			osarch := strings.SplitN(p, "/", 2)
+			name := m.name(osarch[0], osarch[1])
+			if !names[name] {
+				warnings = append(warnings, lintWarning{lintMissingBinary, fmt.Sprintf("%s has no binary", p)})
+				continue
+			}
+			if !names[name+".sha256"] {
+				warnings = append(warnings, lintWarning{lintMissingChecksum, fmt.Sprintf("%s has no .sha256", name)})
+			}
+		}
+	}
+
+	for name := range names {
+		for _, ext := range []string{".sha256", ".md5"} {
+			if strings.HasSuffix(name, ext) && !names[strings.TrimSuffix(name, ext)] {
+				warnings = append(warnings, lintWarning{lintOrphanedChecksum, fmt.Sprintf("%s has no matching binary", name)})
+			}
+		}
+	}
+
+	if s.staleAfter > 0 && rl.Release.PublishedAt != nil {
+		if age := time.Since(rl.Release.GetPublishedAt().Time); age > s.staleAfter {
+			warnings = append(warnings, lintWarning{lintStaleRelease, fmt.Sprintf("released %s ago", units.HumanDuration(age))})
+		}
+	}
+
+	sort.Slice(warnings, func(i, j int) bool {
+		if warnings[i].Check == warnings[j].Check {
+			return warnings[i].Message < warnings[j].Message
+		}
+		return warnings[i].Check < warnings[j].Check
+	})
+	return warnings
+}
+
+// lintReports returns the reports for the releases that have warnings.
+func lintReports(releases []release) []lintReport {
+	reports := []lintReport{}
+	for _, r := range releases {
+		if len(r.Warnings) < 1 {
+			continue
+		}
+		reports = append(reports, lintReport{
+			Repository: r.Repository.GetFullName(),
+			Tag:        r.Release.GetTagName(),
+			Warnings:   r.Warnings,
+		})
+	}
+	return reports
+}
+
+// lintHandler serves the lint reports of the last refresh as JSON.
+func (s *snapshot) lintHandler(w http.ResponseWriter, req *http.Request) {
+	s.mu.RLock()
+	reports := lintReports(s.releases)
+	s.mu.RUnlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(reports)
+}
+
+const lintHelp = `Check the latest release of every repository for missing platforms or checksums.`
+
+func (cmd *lintCommand) Name() string      { return "lint" }
+func (cmd *lintCommand) Args() string      { return "" }
+func (cmd *lintCommand) ShortHelp() string { return lintHelp }
+func (cmd *lintCommand) LongHelp() string  { return lintHelp }
+func (cmd *lintCommand) Hidden() bool      { return false }
+
+func (cmd *lintCommand) Register(fs *flag.FlagSet) {}
+
+type lintCommand struct{}
+
+func (cmd *lintCommand) Run(ctx context.Context, args []string) error {
+	client, affiliation, err := newClient(ctx)
+	if err != nil {
+		return err
+	}
+
+	releases, err := getRepositories(ctx, client, 1, 100, affiliation, []release{})
+	if err != nil {
+		return err
+	}
+
+	reports := lintReports(releases)
+	if len(reports) < 1 {
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 20, 1, 3, ' ', 0)
+	fmt.Fprintln(w, "REPOSITORY\tTAG\tCHECK\tMESSAGE")
+	n := 0
+	for _, r := range reports {
+		for _, warning := range r.Warnings {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", r.Repository, r.Tag, warning.Check, warning.Message)
+			n++
+		}
+	}
+	w.Flush()
+
+	return fmt.Errorf("found %d warnings in %d repositories", n, len(reports))
+}
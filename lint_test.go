@@ -0,0 +1,111 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/github"
+)
+
+func TestLintRelease(t *testing.T) {
+	s := repoSettings{
+		binary:     defaultBinaryPattern,
+		platforms:  []string{"linux/amd64", "linux/arm64", "darwin/amd64"},
+		staleAfter: 365 * 24 * time.Hour,
+	}
+	asset := func(tag, name string, size int) releaseAsset {
+		a := releaseAsset{Tag: tag, Name: name, Size: size}
+		a.OS, a.Arch = newBinaryMatcher(s.binary, "img").platform(name)
+		return a
+	}
+	complete := []releaseAsset{
+		asset("v2", "img-linux-amd64", 10),
+		asset("v2", "img-linux-amd64.sha256", 64),
+		asset("v2", "img-linux-arm64", 10),
+		asset("v2", "img-linux-arm64.sha256", 64),
+		asset("v2", "img-darwin-amd64", 10),
+		asset("v2", "img-darwin-amd64.sha256", 64),
+	}
+
+	testCases := []struct {
+		name      string
+		assets    []releaseAsset
+		published time.Time
+		want      []string
+	}{
+		{
+			name:   "complete",
+			assets: complete,
+		},
+		{
+			name:   "missing platform",
+			assets: complete[:4],
+			want:   []string{"missing-binary: darwin/amd64 has no binary"},
+		},
+		{
+			name:   "missing checksum",
+			assets: append(append([]releaseAsset{}, complete[:3]...), complete[4:]...),
+			want:   []string{"missing-checksum: img-linux-arm64 has no .sha256"},
+		},
+		{
+			name: "missing checksums and platform",
+			assets: []releaseAsset{
+				asset("v2", "img-linux-amd64", 10),
+				asset("v2", "img-linux-arm64", 10),
+			},
+			want: []string{
+				"missing-binary: darwin/amd64 has no binary",
+				"missing-checksum: img-linux-amd64 has no .sha256",
+				"missing-checksum: img-linux-arm64 has no .sha256",
+			},
+		},
+		{
+			name: "only the latest release is checked",
+			assets: append([]releaseAsset{
+				asset("v1", "img-windows-amd64.sha256", 0),
+			}, complete...),
+		},
+		{
+			// Releases without any binaries are not expected to have
+			// the platforms.
+			name: "no binaries",
+			assets: []releaseAsset{
+				asset("v2", "source.tar.gz", 10),
+			},
+		},
+		{
+			name: "empty and orphaned",
+			assets: append([]releaseAsset{
+				asset("v2", "img-linux-386.md5", 0),
+			}, complete...),
+			want: []string{
+				"empty-asset: img-linux-386.md5 is empty",
+				"orphaned-checksum: img-linux-386.md5 has no matching binary",
+			},
+		},
+		{
+			name:      "stale",
+			assets:    complete,
+			published: time.Now().Add(-2 * 365 * 24 * time.Hour),
+			want:      []string{"stale-release: released 2 years ago"},
+		},
+	}
+
+	for _, tc := range testCases {
+		rl := testRelease("genuinetools/img")
+		rl.Release = &github.RepositoryRelease{TagName: github.String("v2")}
+		if !tc.published.IsZero() {
+			rl.Release.PublishedAt = &github.Timestamp{Time: tc.published}
+		}
+		rl.Assets = tc.assets
+
+		var got []string
+		for _, w := range lintRelease(rl, s) {
+			got = append(got, w.Check+": "+w.Message)
+		}
+		if strings.Join(got, "\n") != strings.Join(tc.want, "\n") {
+			t.Errorf("%s: got\n%s\nwant\n%s", tc.name, strings.Join(got, "\n"), strings.Join(tc.want, "\n"))
+		}
+	}
+}
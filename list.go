@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	units "github.com/docker/go-units"
+)
+
+const listHelp = `List the latest release of every repository.`
+
+func (cmd *listCommand) Name() string      { return "list" }
+func (cmd *listCommand) Args() string      { return "" }
+func (cmd *listCommand) ShortHelp() string { return listHelp }
+func (cmd *listCommand) LongHelp() string  { return listHelp }
+func (cmd *listCommand) Hidden() bool      { return false }
+
+func (cmd *listCommand) Register(fs *flag.FlagSet) {
+	fs.BoolVar(&cmd.json, "json", false, "print the releases as JSON")
+}
+
+type listCommand struct {
+	json bool
+}
+
+func (cmd *listCommand) Run(ctx context.Context, args []string) error {
+	client, affiliation, err := newClient(ctx)
+	if err != nil {
+		return err
+	}
+
+	releases, err := getRepositories(ctx, client, 1, 100, affiliation, []release{})
+	if err != nil {
+		return err
+	}
+
+	if cmd.json {
+		b, err := releasesJSON(releases)
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(b))
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 20, 1, 3, ' ', 0)
+	fmt.Fprintln(w, "REPOSITORY\tTAG\tRELEASED\tDOWNLOADS")
+	for _, r := range releases {
+		if r.Release == nil {
+			continue
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s ago\t%d\n", r.Repository.GetFullName(), r.Release.GetTagName(), units.HumanDuration(time.Since(r.Release.GetPublishedAt().Time)), r.BinaryDownloadCount)
+	}
+	return w.Flush()
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// The structured log fields, so the same thing has the same name on every
+// log line.
+const (
+	logFieldRefreshID = "refresh_id"
+	logFieldRepo      = "repo"
+	logFieldTag       = "tag"
+	logFieldAsset     = "asset"
+	logFieldEndpoint  = "endpoint"
+	logFieldDuration  = "duration"
+)
+
+type logFieldsKey struct{}
+
+// setLogFormat sets the format of the logs to text or json.
+func setLogFormat(format string) error {
+	switch format {
+	case "text":
+		logrus.SetFormatter(&logrus.TextFormatter{})
+	case "json":
+		logrus.SetFormatter(&logrus.JSONFormatter{})
+	default:
+		return fmt.Errorf("--log-format must be text or json, got %q", format)
+	}
+	return nil
+}
+
+// withLogFields returns a context that adds fields to the logs written with
+// logFor.
+func withLogFields(ctx context.Context, fields logrus.Fields) context.Context {
+	all := logrus.Fields{}
+	if parent, ok := ctx.Value(logFieldsKey{}).(logrus.Fields); ok {
+		for k, v := range parent {
+			all[k] = v
+		}
+	}
+	for k, v := range fields {
+		all[k] = v
+	}
+	return context.WithValue(ctx, logFieldsKey{}, all)
+}
+
+// logFor returns a logger with the fields of ctx, and the trace id if ctx
+// is traced.
+func logFor(ctx context.Context) *logrus.Entry {
+	e := logrus.NewEntry(logrus.StandardLogger())
+	if fields, ok := ctx.Value(logFieldsKey{}).(logrus.Fields); ok {
+		e = e.WithFields(fields)
+	}
+	if s, ok := ctx.Value(spanKey{}).(*span); ok {
+		e = e.WithField("trace_id", hex.EncodeToString(s.traceID[:]))
+	}
+	return e
+}
+
+// newRefreshID returns a random id to tell the logs of refreshes apart.
+func newRefreshID() string {
+	var b [6]byte
+	rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+// accessLog logs a request served by the HTTP server. The requests of
+// scrapers and probes are only logged at debug level.
+func accessLog(req *http.Request, status, size int, d time.Duration) {
+	remote := req.RemoteAddr
+	if host, _, err := net.SplitHostPort(remote); err == nil {
+		remote = host
+	}
+	log := logrus.WithFields(logrus.Fields{
+		"method":         req.Method,
+		"path":           req.URL.RequestURI(),
+		"status":         status,
+		"bytes":          size,
+		"remote":         remote,
+		"user_agent":     req.UserAgent(),
+		logFieldDuration: d.String(),
+	})
+	if req.URL.Path == "/metrics" || req.URL.Path == "/healthz" {
+		log.Debug("HTTP request")
+		return
+	}
+	log.Info("HTTP request")
+}
@@ -3,17 +3,17 @@ package main
 import (
 	"bytes"
 	"context"
-	"errors"
 	"flag"
 	"fmt"
-	"html/template"
 	"io"
-	"io/ioutil"
+	"net"
 	"net/http"
 	"net/url"
 	"os"
 	"os/signal"
+	"strconv"
 	"strings"
+	"sync"
 	"syscall"
 	"time"
 
@@ -24,22 +24,53 @@ import (
 	"github.com/genuinetools/releases/version"
 	"github.com/google/go-github/github"
 	"github.com/gregjones/httpcache"
-	"github.com/gregjones/httpcache/diskcache"
 	"github.com/sirupsen/logrus"
 )
 
 var (
-	port     int
-	interval time.Duration
+	port            int
+	interval        time.Duration
+	shutdownTimeout time.Duration
+
+	configFile string
 
 	token  string
 	enturl string
 	orgs   stringSlice
 	nouser bool
 
-	updateReleaseBody bool
+	// currentUser is the user the token belongs to, unless --nouser is set.
+	currentUser string
+
+	updateReleaseBody updateMode
+	updateLatest      int
+	updateSince       string
+	updateSinceTime   time.Time
+	updateWorkers     int
+	updater           *bodyUpdater
+
+	stateDir string
+
+	readyIntervals int
+
+	platformsFile string
+	platforms     []string
+	staleAfter    time.Duration
 
-	debug bool
+	notify       stringSlice
+	destinations []*destination
+
+	manifestDir   string
+	changelogMode string
+	templateDir   string
+
+	tmpls *templates
+
+	// traceTarget is where the trace spans are exported to.
+	traceTarget string
+
+	logFormat string
+	debug     bool
 )
 
 // stringSlice is a slice of strings
@@ -54,6 +85,45 @@ func (s *stringSlice) Set(value string) error {
 	return nil
 }
 
+// updateMode is the mode for updating release bodies, it can be set like a
+// boolean flag or to "dry-run".
+type updateMode string
+
+const (
+	updateModeOff    updateMode = "false"
+	updateModeOn     updateMode = "true"
+	updateModeDryRun updateMode = "dry-run"
+)
+
+// implement the flag interface for updateMode
+func (m *updateMode) String() string {
+	if *m == "" {
+		return string(updateModeOff)
+	}
+	return string(*m)
+}
+func (m *updateMode) Set(value string) error {
+	if value == string(updateModeDryRun) {
+		*m = updateModeDryRun
+		return nil
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return fmt.Errorf("must be a boolean or %q", updateModeDryRun)
+	}
+	*m = updateModeOff
+	if b {
+		*m = updateModeOn
+	}
+	return nil
+}
+func (m *updateMode) IsBoolFlag() bool {
+	return true
+}
+func (m updateMode) enabled() bool {
+	return m == updateModeOn || m == updateModeDryRun
+}
+
 func main() {
 	// Create a new cli program.
 	p := cli.NewProgram()
@@ -66,119 +136,182 @@ func main() {
 
 	// Setup the global flags.
 	p.FlagSet = flag.NewFlagSet("global", flag.ExitOnError)
-	p.FlagSet.IntVar(&port, "port", 8080, "port for the server to listen on")
-	p.FlagSet.IntVar(&port, "p", 8080, "port for the server to listen on")
-	p.FlagSet.DurationVar(&interval, "interval", time.Hour, "interval on which to refetch release data")
-
-	p.FlagSet.StringVar(&token, "token", os.Getenv("GITHUB_TOKEN"), "GitHub API token (or env var GITHUB_TOKEN)")
-	p.FlagSet.StringVar(&enturl, "url", "", "GitHub Enterprise URL")
-	p.FlagSet.Var(&orgs, "orgs", "organizations to include")
-	p.FlagSet.BoolVar(&nouser, "nouser", false, "do not include your user")
-
-	p.FlagSet.BoolVar(&updateReleaseBody, "update-release-body", false, "update the body message for the release as well")
-
-	p.FlagSet.BoolVar(&debug, "d", false, "enable debug logging")
+	registerFlags(p.FlagSet)
+
+	// Build the list of available commands.
+	p.Commands = []cli.Command{
+		&cacheCommand{},
+		&exportCommand{},
+		&installCommand{},
+		&latestCommand{},
+		&lintCommand{},
+		&listCommand{},
+		&showCommand{},
+	}
+	for i, c := range p.Commands {
+		p.Commands[i] = flushSpans{c}
+	}
 
 	// Set the before function.
 	p.Before = func(ctx context.Context) error {
-		// Set the log level.
+		// Set the log level and format.
 		if debug {
 			logrus.SetLevel(logrus.DebugLevel)
 		}
-
-		if len(token) < 1 {
-			return fmt.Errorf("GitHub token cannot be empty")
+		if err := setLogFormat(logFormat); err != nil {
+			return err
 		}
 
-		if nouser && orgs == nil {
-			return fmt.Errorf("no organizations provided")
+		if traceTarget != "" {
+			var err error
+			if tracer, err = newSpanExporter(traceTarget); err != nil {
+				return err
+			}
 		}
-		return nil
+
+		return configure(p.FlagSet)
 	}
 
 	// Set the main program action.
 	p.Action = func(ctx context.Context, args []string) error {
+		// Send the spans that were not exported yet.
+		defer tracer.shutdown()
+
 		ticker := time.NewTicker(interval)
+		// The port and --update-release-body cannot be changed by a reload.
+		addr := fmt.Sprintf(":%d", port)
+		mode := updateReleaseBody
 
-		// On ^C, or SIGTERM handle exit.
-		signals := make(chan os.Signal)
+		// On ^C, or SIGTERM shut down gracefully.
+		signals := make(chan os.Signal, 1)
 		signal.Notify(signals, os.Interrupt)
 		signal.Notify(signals, syscall.SIGTERM)
+
+		// On SIGHUP reload the config.
+		hup := make(chan os.Signal, 1)
+		signal.Notify(hup, syscall.SIGHUP)
+
+		// The release body updates and notifications get the parent
+		// context, so those in progress can finish when we shut down.
+		updateCtx := ctx
 		var cancel context.CancelFunc
 		ctx, cancel = context.WithCancel(ctx)
+		defer cancel()
+
+		// Stop refreshing on the first signal, even during the first
+		// refresh, and exit right away on the second.
 		go func() {
-			for sig := range signals {
-				cancel()
-				ticker.Stop()
-				logrus.Infof("Received %s, exiting.", sig.String())
-				os.Exit(0)
-			}
+			sig := <-signals
+			logrus.Infof("Received %s, shutting down...", sig.String())
+			cancel()
+
+			sig = <-signals
+			logrus.Warnf("Received %s again, exiting now.", sig.String())
+			os.Exit(1)
 		}()
 
-		// Create the http client.
-		ts := oauth2.StaticTokenSource(
-			&oauth2.Token{AccessToken: token},
-		)
+		client, affiliation, err := newClient(ctx)
+		if err != nil {
+			return err
+		}
 
-		// Create the HTTP cache.
-		cachePath := "/tmp/cache"
-		if err := os.MkdirAll(cachePath, 0777); err != nil {
-			logrus.Fatal(err)
+		if mode == updateModeOn {
+			updater, err = newBodyUpdater(client)
+			if err != nil {
+				return err
+			}
+			updater.start(updateCtx, updateWorkers)
 		}
-		cache := diskcache.New(cachePath)
-		tr := httpcache.NewTransport(cache)
-		c := &http.Client{Transport: tr}
-		ctx = context.WithValue(ctx, oauth2.HTTPClient, c)
 
-		// Create the github client.
-		tc := oauth2.NewClient(ctx, ts)
-		client := github.NewClient(tc)
-		if enturl != "" {
-			var err error
-			client.BaseURL, err = url.Parse(enturl + "/api/v3/")
+		var n *notifier
+		if len(destinations) > 0 {
+			n, err = newNotifier(updateCtx, destinations)
 			if err != nil {
-				logrus.Fatal(err)
+				return err
 			}
 		}
 
-		// Affiliation must be set before we add the user to the "orgs".
-		affiliation := "owner,collaborator"
-		if len(orgs) > 0 {
-			affiliation += ",organization_member"
+		// Start from the snapshot saved on shutdown, so we have something
+		// to serve if the first refresh fails.
+		var snap snapshot
+		if err := snap.load(); err != nil {
+			logrus.Warnf("loading the previous snapshot failed: %v", err)
 		}
 
-		if !nouser {
-			// Get the current user
-			user, _, err := client.Users.Get(ctx, "")
+		// Fetch new data and render the template every interval sequence.
+		refresh := func() {
+			start := time.Now()
+			refreshState.started(start)
+			rctx := withLogFields(ctx, logrus.Fields{logFieldRefreshID: newRefreshID()})
+			rls, bt, err := run(rctx, client, affiliation)
+			if ctx.Err() != nil {
+				// We are shutting down, do not replace the snapshot with
+				// partial data.
+				return
+			}
+			refreshState.finished(time.Now(), err)
+			recordRefresh(start, rls, err)
+			log := logFor(rctx).WithField(logFieldDuration, time.Since(start).String())
 			if err != nil {
-				if v, ok := err.(*github.RateLimitError); ok {
-					logrus.Fatalf("%s Limit: %d; Remaining: %d; Retry After: %s", v.Message, v.Rate.Limit, v.Rate.Remaining, time.Until(v.Rate.Reset.Time).String())
-				}
+				log.Warn(err)
+				return
+			}
+			log.WithField("releases", len(rls)).Info("Refreshed the release data.")
+			snap.set(rls, bt, time.Now())
+			downloadStats.record(rls, time.Now())
+			if n != nil {
+				n.check(rctx, rls)
+			}
 
-				logrus.Fatal(err)
+			if manifestDir != "" {
+				if err := writeManifests(rctx, manifestDir, rls); err != nil {
+					log.Warnf("writing manifests to %s failed: %v", manifestDir, err)
+				}
 			}
-			username := *user.Login
-			// add the current user to orgs
-			orgs = append(orgs, username)
 		}
 
-		var (
-			b   bytes.Buffer
-			err error
-		)
-
-		// Fetch new data and render the template every interval sequence.
-		b, err = run(ctx, client, affiliation)
+		// Listen before the first refresh, which can take a while, so the
+		// snapshot and the health checks are served in the meantime.
+		ln, err := net.Listen("tcp", addr)
 		if err != nil {
-			logrus.Warn(err)
+			return err
 		}
+		logrus.Infof("Starting server on %s...", addr)
+
+		refreshDone := make(chan struct{})
 		go func() {
-			for range ticker.C {
-				bt, err := run(ctx, client, affiliation)
-				if err != nil {
-					logrus.Warn(err)
-				} else {
-					b = bt
+			defer close(refreshDone)
+			refresh()
+			for {
+				select {
+				case <-ctx.Done():
+					ticker.Stop()
+					return
+				case <-ticker.C:
+					refresh()
+				case <-hup:
+					logrus.Info("Received SIGHUP, reloading the config...")
+					c, a, err := reload(ctx, p.FlagSet)
+					if err != nil {
+						logrus.Warnf("reloading the config failed, keeping the previous one: %v", err)
+						continue
+					}
+					client, affiliation = c, a
+					if updater != nil {
+						updater.setClient(client)
+					}
+
+					if n != nil {
+						n.setDestinations(destinations)
+					} else if len(destinations) > 0 {
+						if n, err = newNotifier(updateCtx, destinations); err != nil {
+							logrus.Warn(err)
+						}
+					}
+
+					ticker.Stop()
+					ticker = time.NewTicker(interval)
+					refresh()
 				}
 			}
 		}()
@@ -188,13 +321,108 @@ func main() {
 
 		// Define wildcard/root handler.
 		mux.HandleFunc("/", func(w http.ResponseWriter, req *http.Request) {
+			if req.URL.Path != "/" {
+				snap.repoHandler(w, req)
+				return
+			}
+
 			w.Header().Set("Content-Type", "text/html")
-			fmt.Fprint(w, b.String())
+			fmt.Fprint(w, snap.index())
 		})
 
-		logrus.Infof("Starting server on port %d...", port)
-		logrus.Fatal(http.ListenAndServe(fmt.Sprintf(":%d", port), mux))
+		if updater != nil {
+			// Show the progress of the release body updates.
+			mux.HandleFunc("/updates", func(w http.ResponseWriter, req *http.Request) {
+				w.Header().Set("Content-Type", "text/plain")
+				fmt.Fprint(w, updater.String())
+			})
+		}
+
+		if mode == updateModeDryRun {
+			// Show the changes the release body updates would make.
+			mux.HandleFunc("/dry-run", func(w http.ResponseWriter, req *http.Request) {
+				w.Header().Set("Content-Type", "text/plain")
+				fmt.Fprint(w, dryRunDiffs.String())
+			})
+		}
+
+		// Define the package manifest handlers.
+		mux.HandleFunc("/scoop/", snap.manifestHandler("/scoop/", ".json", "application/json", scoopManifestBytes))
+		mux.HandleFunc("/winget/", snap.manifestHandler("/winget/", ".yaml", "application/x-yaml", wingetManifestBytes))
+		mux.HandleFunc("/aur/", snap.manifestHandler("/aur/", "/PKGBUILD", "text/plain", pkgbuildBytes))
+		mux.HandleFunc("/nix/", snap.manifestHandler("/nix/", ".nix", "text/plain", nixBytes))
+
+		// Define the download stats handlers.
+		mux.HandleFunc("/stats/", snap.statsHandler("/stats/", false))
+		mux.HandleFunc("/api/v1/stats/", snap.statsHandler("/api/v1/stats/", true))
+		mux.HandleFunc("/analytics", snap.analyticsHandler)
+		mux.HandleFunc("/api/v1/analytics.json", snap.analyticsHandler)
+		mux.HandleFunc("/api/v1/analytics.csv", snap.analyticsHandler)
+		mux.HandleFunc("/api/v1/lint", snap.lintHandler)
+		mux.HandleFunc("/api/v1/lint.json", snap.lintHandler)
+
+		// Define the JSON, feed and badge handlers.
+		mux.HandleFunc("/api/v1/releases.json", snap.releasesHandler)
+		mux.HandleFunc("/api/v1/releases/", snap.manifestHandler("/api/v1/releases/", ".json", "application/json", releaseJSON))
+		mux.HandleFunc("/feed.atom", func(w http.ResponseWriter, req *http.Request) {
+			snap.mu.RLock()
+			b, err := releasesFeed("Latest Releases", "https://"+req.Host+"/", snap.releases)
+			snap.mu.RUnlock()
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			w.Header().Set("Content-Type", "application/atom+xml")
+			w.Write(b)
+		})
+		mux.HandleFunc("/feeds/", snap.manifestHandler("/feeds/", ".atom", "application/atom+xml", repoFeed))
+		mux.HandleFunc("/badges/release/", snap.manifestHandler("/badges/release/", ".svg", "image/svg+xml", releaseBadge))
+		mux.HandleFunc("/badges/downloads/", snap.manifestHandler("/badges/downloads/", ".svg", "image/svg+xml", downloadsBadge))
+
+		// Define the metrics, health and status handlers.
+		mux.HandleFunc("/metrics", metricsHandler)
+		mux.HandleFunc("/healthz", healthHandler)
+		mux.HandleFunc("/readyz", snap.readyHandler)
+		mux.HandleFunc("/status", snap.statusHandler)
+
+		server := &http.Server{
+			Addr:    addr,
+			Handler: instrument(mux),
+		}
+
+		shutdownDone := make(chan struct{})
+		go func() {
+			defer close(shutdownDone)
+
+			<-ctx.Done()
 
+			sctx, scancel := context.WithTimeout(context.Background(), shutdownTimeout)
+			defer scancel()
+
+			// Wait for the requests in flight and the refresh to stop.
+			if err := server.Shutdown(sctx); err != nil {
+				logrus.Warnf("shutting down the server failed: %v", err)
+			}
+			waitFor(sctx, "the refresh to stop", func() { <-refreshDone })
+			if updater != nil {
+				waitFor(sctx, "the release body updates in progress", updater.stop)
+			}
+			if n != nil {
+				waitFor(sctx, "the notifications being sent", n.wait)
+			}
+
+			if err := snap.save(); err != nil {
+				logrus.Warnf("saving the snapshot failed: %v", err)
+			}
+		}()
+
+		// Return the error rather than exiting, so the spans are flushed.
+		if err := server.Serve(ln); err != http.ErrServerClosed {
+			return err
+		}
+		<-shutdownDone
+
+		logrus.Info("Shut down.")
 		return nil
 	}
 
@@ -202,6 +430,202 @@ func main() {
 	p.Run()
 }
 
+// registerFlags registers the global flags on fs.
+func registerFlags(fs *flag.FlagSet) {
+	fs.IntVar(&port, "port", 8080, "port for the server to listen on")
+	fs.IntVar(&port, "p", 8080, "port for the server to listen on")
+	fs.DurationVar(&interval, "interval", time.Hour, "interval on which to refetch release data")
+	fs.DurationVar(&shutdownTimeout, "shutdown-timeout", 30*time.Second, "how long to wait for requests and release body updates in progress when shutting down")
+	fs.StringVar(&configFile, "config", "", "TOML config file, flags take precedence over it")
+
+	fs.StringVar(&token, "token", os.Getenv("GITHUB_TOKEN"), "GitHub API token (or env var GITHUB_TOKEN)")
+	fs.Int64Var(&appID, "app-id", 0, "authenticate as this GitHub App instead of with a token")
+	fs.StringVar(&appKeyFile, "app-key", "", "file with the private key of the GitHub App")
+	fs.Int64Var(&appInstallationID, "app-installation-id", 0, "installation of the GitHub App to use, by default the one on the first of --orgs")
+	fs.BoolVar(&anonymous, "anonymous", false, "use the GitHub API without a token, only for public repositories of --orgs")
+	fs.StringVar(&enturl, "url", "", "GitHub Enterprise URL")
+	fs.Var(&orgs, "orgs", "organizations to include")
+	fs.BoolVar(&nouser, "nouser", false, "do not include your user")
+
+	fs.Var(&updateReleaseBody, "update-release-body", "update the body message for the release as well, or only show the changes with dry-run")
+	fs.IntVar(&updateLatest, "update-latest", 0, "only update the body of the latest N releases of each repository (0 for all)")
+	fs.StringVar(&updateSince, "update-since", "", "only update the body of releases created after this date (YYYY-MM-DD)")
+	fs.IntVar(&updateWorkers, "update-workers", 2, "number of concurrent release body updates")
+	fs.StringVar(&changelogMode, "changelog", "", "add a changelog to the release body grouped by conventional commit type or pull request label (conventional or labels)")
+
+	fs.StringVar(&stateDir, "state-dir", "/tmp/releases", "directory to persist state in")
+	fs.StringVar(&cacheBackend, "cache", cacheDisk, "where to cache the GitHub API responses, memory, disk or none")
+	fs.StringVar(&cacheDir, "cache-dir", "/tmp/cache", "directory to cache the GitHub API responses in")
+	fs.Var(&cacheMaxSize, "cache-max-size", "evict the least recently used GitHub API responses once the cache is larger than this (0 for no limit)")
+	fs.DurationVar(&downloadTimeout, "download-timeout", 30*time.Second, "how long a single attempt to download a checksum may take")
+	fs.IntVar(&readyIntervals, "ready-intervals", 3, "report not ready on /readyz if the release data is older than this many intervals (0 to disable)")
+
+	fs.StringVar(&platformsFile, "platforms", "", "file listing the os/arch every release should have a binary for, instead of those releases is built for")
+	fs.DurationVar(&staleAfter, "stale-after", 365*24*time.Hour, "warn about latest releases older than this (0 to disable)")
+
+	fs.Var(&notify, "notify", "send notifications for new releases to kind=url[#repos=owner/repo,...], kind is webhook, slack, matrix or smtp")
+
+	fs.StringVar(&manifestDir, "manifest-dir", "", "directory to write generated package manifests to after every refresh")
+	fs.StringVar(&templateDir, "template-dir", "", "directory with templates overriding the built-in index.html, repo.html and release-body.md")
+
+	fs.StringVar(&traceTarget, "trace", "", "export trace spans to stderr or to the url of an OTLP/HTTP collector, like http://localhost:4318/v1/traces")
+
+	fs.StringVar(&logFormat, "log-format", "text", "format of the logs, text or json")
+	fs.BoolVar(&debug, "d", false, "enable debug logging")
+}
+
+// configure validates the flags, applies the config file and loads the
+// platforms, notification destinations and templates. It runs at startup and
+// again on SIGHUP.
+func configure(fs *flag.FlagSet) error {
+	var err error
+	platforms, err = loadPlatforms(platformsFile)
+	if err != nil {
+		return fmt.Errorf("loading platforms failed: %v", err)
+	}
+
+	if configFile != "" {
+		if err := loadConfig(configFile, fs); err != nil {
+			return fmt.Errorf("loading config failed: %v", err)
+		}
+	}
+
+	if nouser && orgs == nil {
+		return fmt.Errorf("no organizations provided")
+	}
+
+	updateSinceTime = time.Time{}
+	if updateSince != "" {
+		var err error
+		updateSinceTime, err = time.Parse("2006-01-02", updateSince)
+		if err != nil {
+			return fmt.Errorf("parsing --update-since %q failed, expected YYYY-MM-DD: %v", updateSince, err)
+		}
+	}
+
+	if changelogMode != "" && changelogMode != changelogConventional && changelogMode != changelogLabels {
+		return fmt.Errorf("--changelog must be %q or %q", changelogConventional, changelogLabels)
+	}
+
+	if updateWorkers < 1 {
+		return fmt.Errorf("--update-workers must be at least 1")
+	}
+
+	if err := validateCache(); err != nil {
+		return err
+	}
+
+	if err := validateAuth(); err != nil {
+		return err
+	}
+
+	destinations = nil
+	for _, n := range notify {
+		d, err := parseDestination(n)
+		if err != nil {
+			return err
+		}
+		destinations = append(destinations, d)
+	}
+
+	// Parse the templates.
+	tmpls, err = loadTemplates(templateDir)
+	if err != nil {
+		return fmt.Errorf("loading templates failed: %v", err)
+	}
+	return nil
+}
+
+// newClient creates the GitHub client and returns it along with the
+// affiliation to list repositories with. The repositories of the current
+// user are included unless --nouser is set.
+func newClient(ctx context.Context) (*github.Client, string, error) {
+	c := currentConfig()
+	client, affiliation, err := c.newClient(ctx)
+	if err != nil {
+		return nil, "", err
+	}
+	downloadClient, currentUser = c.downloadClient, c.currentUser
+	return client, affiliation, nil
+}
+
+// newClient creates the GitHub client of the config without changing the
+// globals, it sets the download client and current user of the config.
+func (sc *savedConfig) newClient(ctx context.Context) (*github.Client, string, error) {
+	mode := sc.authMode()
+	if mode == "" {
+		return nil, "", fmt.Errorf("GitHub token cannot be empty, use --app-id and --app-key for a GitHub App or --anonymous for public repositories")
+	}
+
+	baseURL, err := url.Parse("https://api.github.com/")
+	if sc.enturl != "" {
+		baseURL, err = url.Parse(sc.enturl + "/api/v3/")
+	}
+	if err != nil {
+		return nil, "", err
+	}
+
+	// Use the HTTP cache, unless it is disabled.
+	var tr http.RoundTripper = http.DefaultTransport
+	var cache *lruCache
+	cache, err = getHTTPCache()
+	if err != nil {
+		return nil, "", fmt.Errorf("creating the HTTP cache failed: %v", err)
+	}
+	if cache != nil {
+		tr = httpcache.NewTransport(cache)
+	}
+	c := &http.Client{Transport: &tracingTransport{base: &metricsTransport{base: tr}}}
+	// The assets GitHub redirects to are not API requests, so they are
+	// neither cached nor counted in the API metrics.
+	sc.downloadClient = &http.Client{Transport: http.DefaultTransport.(*http.Transport).Clone(), Timeout: sc.downloadTimeout}
+	ctx = context.WithValue(ctx, oauth2.HTTPClient, c)
+
+	// Create the github client.
+	var ts oauth2.TokenSource
+	switch mode {
+	case authToken:
+		ts = oauth2.StaticTokenSource(
+			&oauth2.Token{AccessToken: token},
+		)
+	case authApp:
+		// Creating the installation tokens is not cached.
+		ts, err = newAppTokenSource(ctx, *sc, &tracingTransport{base: &metricsTransport{base: http.DefaultTransport}}, baseURL)
+		if err != nil {
+			return nil, "", err
+		}
+	}
+	hc := c
+	if ts != nil {
+		hc = oauth2.NewClient(ctx, ts)
+	}
+	client := github.NewClient(hc)
+	client.BaseURL = baseURL
+
+	affiliation := "owner,collaborator"
+	if len(sc.orgs) > 0 {
+		affiliation += ",organization_member"
+	}
+
+	// Only a user has repositories of its own.
+	var username string
+	if !sc.nouser && mode == authToken {
+		// Get the current user
+		user, _, err := client.Users.Get(ctx, "")
+		if err != nil {
+			if v, ok := err.(*github.RateLimitError); ok {
+				return nil, "", fmt.Errorf("%s Limit: %d; Remaining: %d; Retry After: %s", v.Message, v.Rate.Limit, v.Rate.Remaining, time.Until(v.Rate.Reset.Time).String())
+			}
+
+			return nil, "", err
+		}
+		username = user.GetLogin()
+	}
+	sc.currentUser = username
+
+	return client, affiliation, nil
+}
+
 type release struct {
 	Repository          *github.Repository
 	Release             *github.RepositoryRelease
@@ -211,9 +635,138 @@ type release struct {
 	BinaryMD5           string
 	BinaryDownloadCount int
 	BinarySince         string
+
+	// Platforms holds the binaries for the latest release keyed by
+	// os -> arch.
+	Platforms map[string]map[string]release
+	// Assets holds the assets of all releases.
+	Assets []releaseAsset
+	// Warnings holds the problems found with the latest release.
+	Warnings []lintWarning
+	// Published holds the releases that are not drafts, newest first.
+	Published []publishedRelease
 }
 
-func run(ctx context.Context, client *github.Client, affiliation string) (bytes.Buffer, error) {
+// publishedRelease holds the information about a release that is not a
+// draft, to notify about it.
+type publishedRelease struct {
+	ID          int64
+	Tag         string
+	Name        string
+	URL         string
+	PublishedAt time.Time
+}
+
+// releaseAsset holds the information about a single release asset.
+type releaseAsset struct {
+	Tag           string
+	Name          string
+	OS            string
+	Arch          string
+	Size          int
+	DownloadCount int
+	CreatedAt     time.Time
+}
+
+// snapshot holds the results of the most recent refresh.
+type snapshot struct {
+	mu       sync.RWMutex
+	releases []release
+	b        bytes.Buffer
+	updated  time.Time
+}
+
+func (s *snapshot) set(releases []release, b bytes.Buffer, updated time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.releases = releases
+	s.b = b
+	s.updated = updated
+}
+
+func (s *snapshot) index() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.b.String()
+}
+
+// find returns the release for the repository matching owner/name.
+func (s *snapshot) find(owner, name string) (release, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return findRelease(s.releases, owner, name)
+}
+
+func findRelease(releases []release, owner, name string) (release, bool) {
+	for _, r := range releases {
+		if strings.EqualFold(r.Repository.GetOwner().GetLogin(), owner) && strings.EqualFold(r.Repository.GetName(), name) {
+			return r, true
+		}
+	}
+	return release{}, false
+}
+
+// manifestHandler serves the manifest generated by fn for requests to
+// prefix + "{owner}/{repo}" + ext.
+func (s *snapshot) manifestHandler(prefix, ext, contentType string, fn func(release) ([]byte, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		parts := strings.SplitN(strings.TrimSuffix(strings.TrimPrefix(req.URL.Path, prefix), ext), "/", 2)
+		if len(parts) != 2 {
+			http.NotFound(w, req)
+			return
+		}
+
+		r, ok := s.find(parts[0], parts[1])
+		if !ok {
+			http.NotFound(w, req)
+			return
+		}
+
+		b, err := fn(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+
+		w.Header().Set("Content-Type", contentType)
+		w.Write(b)
+	}
+}
+
+// repoHandler renders the page for the repository at /{owner}/{repo}.
+func (s *snapshot) repoHandler(w http.ResponseWriter, req *http.Request) {
+	parts := strings.Split(strings.Trim(req.URL.Path, "/"), "/")
+	if len(parts) != 2 {
+		http.NotFound(w, req)
+		return
+	}
+
+	r, ok := s.find(parts[0], parts[1])
+	if !ok {
+		http.NotFound(w, req)
+		return
+	}
+
+	b, err := renderRepo(r)
+	if err != nil {
+		logrus.WithField(logFieldRepo, r.Repository.GetFullName()).Warnf("executing repo template failed: %v", err)
+		http.Error(w, "executing template failed", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html")
+	w.Write(b)
+}
+
+func renderRepo(r release) ([]byte, error) {
+	var b bytes.Buffer
+	if err := currentTemplates().repo.Execute(&b, r); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+func run(ctx context.Context, client *github.Client, affiliation string) ([]release, bytes.Buffer, error) {
 	var (
 		page     = 1
 		perPage  = 100
@@ -222,40 +775,130 @@ func run(ctx context.Context, client *github.Client, affiliation string) (bytes.
 		err      error
 	)
 
-	logrus.Info("Getting repositories...")
+	ctx, span := startSpan(ctx, "run", spanKindInternal)
+	defer span.finish()
+
+	if updateReleaseBody == updateModeDryRun {
+		dryRunDiffs.reset()
+	}
+
+	log := logFor(ctx)
+	log.Info("Getting repositories...")
+	metricReposScanned.set(0)
 	releases, err = getRepositories(ctx, client, page, perPage, affiliation, releases)
+	if updateReleaseBody == updateModeDryRun {
+		log.Infof("Dry run: %s", dryRunDiffs.publish())
+	}
+	span.set("releases", len(releases))
 	if err != nil {
+		span.fail(err)
 		if v, ok := err.(*github.RateLimitError); ok {
-			logrus.Warnf("%s Limit: %d; Remaining: %d; Retry After: %s", v.Message, v.Rate.Limit, v.Rate.Remaining, time.Until(v.Rate.Reset.Time).String())
-			return b, nil
+			log.WithFields(logrus.Fields{
+				"limit":       v.Rate.Limit,
+				"remaining":   v.Rate.Remaining,
+				"retry_after": time.Until(v.Rate.Reset.Time).String(),
+			}).Warn(v.Message)
+			return nil, b, fmt.Errorf("rate limited, keeping the previous data")
 		}
 
-		logrus.Warnf("getting repositories failed: %v", err)
+		if len(releases) < 1 {
+			return nil, b, fmt.Errorf("getting repositories failed, keeping the previous data: %v", err)
+		}
+		log.Warnf("getting repositories failed: %v", err)
 	}
 
-	// Parse the template.
-	logrus.Info("Executing template...")
-	t := template.Must(template.New("").Parse(tmpl))
-	w := io.Writer(&b)
-
 	// Execute the template.
-	err = t.Execute(w, releases)
-	return b, err
+	log.Info("Executing template...")
+	w := io.Writer(&b)
+	err = currentTemplates().index.Execute(w, releases)
+	return releases, b, err
 }
 
 func getRepositories(ctx context.Context, client *github.Client, page, perPage int, affiliation string, releases []release) ([]release, error) {
-	opt := &github.RepositoryListOptions{
-		Visibility:  "public",
-		Affiliation: affiliation,
-		ListOptions: github.ListOptions{
-			Page:    page,
-			PerPage: perPage,
-		},
-	}
-	repos, resp, err := client.Repositories.List(ctx, "", opt)
-	if err != nil {
+	if authMode() == authToken {
+		return getOwnerRepositories(ctx, client, "", page, perPage, affiliation, releases)
+	}
+
+	// Only a user can list the repositories it has access to, otherwise we
+	// list those of every org.
+	if len(orgs) < 1 {
+		return releases, fmt.Errorf("--orgs is required with a GitHub App or --anonymous")
+	}
+	var failed int
+	for _, owner := range orgs {
+		var err error
+		releases, err = getOwnerRepositories(ctx, client, owner, page, perPage, affiliation, releases)
+		if err == nil {
+			continue
+		}
+		if _, ok := err.(*github.RateLimitError); ok || ctx.Err() != nil {
+			return releases, err
+		}
+
+		// Skip the owner, but keep going with the others.
+		logFor(ctx).WithField("owner", owner).Warnf("getting repositories failed: %v", err)
+		refreshState.repoError(owner, err)
+		if failed++; failed == len(orgs) {
+			return releases, err
+		}
+	}
+	return releases, nil
+}
+
+// getOwnerRepositories handles the repositories of the org owner, or those
+// the user has access to if owner is empty, starting at page.
+func getOwnerRepositories(ctx context.Context, client *github.Client, owner string, page, perPage int, affiliation string, releases []release) ([]release, error) {
+	releases, next, err := getRepositoriesPage(ctx, client, owner, page, perPage, affiliation, releases)
+	if err := checksums.save(); err != nil {
+		logFor(ctx).Warnf("saving %s failed: %v", checksumStateFile, err)
+	}
+	if err != nil || next == 0 {
 		return releases, err
 	}
+	return getOwnerRepositories(ctx, client, owner, next, perPage, affiliation, releases)
+}
+
+// getRepositoriesPage handles the repositories on a page, it returns the
+// next page or 0 if it was the last one.
+func getRepositoriesPage(ctx context.Context, client *github.Client, owner string, page, perPage int, affiliation string, releases []release) (_ []release, next int, err error) {
+	ctx, span := startSpan(ctx, "getRepositories", spanKindInternal, attr("page", page))
+	defer func() {
+		span.fail(err)
+		span.finish()
+	}()
+
+	lo := github.ListOptions{
+		Page:    page,
+		PerPage: perPage,
+	}
+	var (
+		repos []*github.Repository
+		resp  *github.Response
+	)
+	if owner != "" {
+		span.set("owner", owner)
+		repos, resp, err = client.Repositories.ListByOrg(ctx, owner, &github.RepositoryListByOrgOptions{
+			Type:        "public",
+			ListOptions: lo,
+		})
+		if resp != nil && resp.StatusCode == http.StatusNotFound {
+			// The owner is a user rather than an org.
+			repos, resp, err = client.Repositories.List(ctx, owner, &github.RepositoryListOptions{
+				Type:        "owner",
+				ListOptions: lo,
+			})
+		}
+	} else {
+		repos, resp, err = client.Repositories.List(ctx, "", &github.RepositoryListOptions{
+			Visibility:  "public",
+			Affiliation: affiliation,
+			ListOptions: lo,
+		})
+	}
+	if err != nil {
+		return releases, 0, err
+	}
+	span.set("repositories", len(repos))
 
 	for _, repo := range repos {
 		// Skip it if it's archived.
@@ -263,10 +906,34 @@ func getRepositories(ctx context.Context, client *github.Client, page, perPage i
 			continue
 		}
 
-		logrus.Debugf("Handling repo %s...", *repo.FullName)
-		r, err := handleRepo(ctx, client, repo)
+		// Skip it if it's not owned by one of the orgs or the current user.
+		if owner := repo.GetOwner().GetLogin(); !in(orgs, owner) && owner != currentUser {
+			continue
+		}
+
+		// Skip it if the config filters it out.
+		if settingsFor(repo.GetFullName()).skip {
+			continue
+		}
+
+		metricReposScanned.inc()
+		rctx, rspan := startSpan(ctx, "handleRepo", spanKindInternal, attr("repository", repo.GetFullName()))
+		rctx = withLogFields(rctx, logrus.Fields{logFieldRepo: repo.GetFullName()})
+		start := time.Now()
+		logFor(rctx).Debug("Handling repo...")
+		r, err := handleRepo(rctx, client, repo)
+		rspan.fail(err)
+		rspan.finish()
+		logFor(rctx).WithField(logFieldDuration, time.Since(start).String()).Debug("Handled repo.")
 		if err != nil {
-			return releases, err
+			if _, ok := err.(*github.RateLimitError); ok || ctx.Err() != nil {
+				return releases, 0, err
+			}
+
+			// Skip the repository, but keep going with the others.
+			logFor(rctx).Warnf("handling repo failed: %v", err)
+			refreshState.repoError(repo.GetFullName(), err)
+			continue
 		}
 		if r != nil {
 			releases = append(releases, *r)
@@ -275,45 +942,53 @@ func getRepositories(ctx context.Context, client *github.Client, page, perPage i
 
 	// Return early if we are on the last page.
 	if page == resp.LastPage || resp.NextPage == 0 {
-		return releases, nil
+		return releases, 0, nil
 	}
 
-	page = resp.NextPage
-	return getRepositories(ctx, client, page, perPage, affiliation, releases)
+	return releases, resp.NextPage, nil
 }
 
 // handleRepo will return nil error if the user does not have access to something.
 func handleRepo(ctx context.Context, client *github.Client, repo *github.Repository) (*release, error) {
-	if !in(orgs, repo.GetOwner().GetLogin()) {
-		// return early
-		return nil, nil
-	}
 	opt := &github.ListOptions{
 		Page:    1,
 		PerPage: 100,
 	}
 
 	releases, resp, err := client.Repositories.ListReleases(ctx, repo.GetOwner().GetLogin(), repo.GetName(), opt)
-	if resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusForbidden || err != nil {
-		if _, ok := err.(*github.RateLimitError); ok {
-			return nil, err
-		}
-
+	if _, ok := err.(*github.RateLimitError); ok {
+		return nil, err
+	}
+	if resp != nil && (resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusForbidden) {
 		// Skip it because there is no release.
 		return nil, nil
 	}
-	if err != nil || len(releases) < 1 {
+	if err != nil {
 		return nil, err
 	}
+	if len(releases) < 1 {
+		return nil, nil
+	}
 
 	rl := release{
 		Repository: repo,
 	}
+	s := settingsFor(repo.GetFullName())
+	m := newBinaryMatcher(s.binary, repo.GetName())
 	// Get information about the binary assets.
-	arch := "linux-amd64"
 	for i := 0; i < len(releases); i++ {
 		r := releases[i]
 
+		if !r.GetDraft() {
+			rl.Published = append(rl.Published, publishedRelease{
+				ID:          r.GetID(),
+				Tag:         r.GetTagName(),
+				Name:        r.GetName(),
+				URL:         r.GetHTMLURL(),
+				PublishedAt: r.GetPublishedAt().Time,
+			})
+		}
+
 		isLatest := false
 		if rl.Release == nil && !r.GetDraft() {
 			// If this is the latest release and it's not a draft make it the one
@@ -324,168 +999,240 @@ func handleRepo(ctx context.Context, client *github.Client, repo *github.Reposit
 
 		// This holds data like os -> arch -> release and we will use it for rendering our
 		// release body template.
-		allReleases := map[string]map[string]release{}
-
-		// Iterate over the assets.
-		for _, asset := range r.Assets {
-			rl.BinaryDownloadCount += asset.GetDownloadCount()
-
-			if !strings.Contains(asset.GetName(), ".") {
-				// We know we are on a binary and not a hashsum.
-				suffix := strings.SplitN(strings.TrimPrefix(asset.GetName(), repo.GetName()+"-"), "-", 2)
-				if len(suffix) == 2 {
-					// Add this to our overall releases map.
-					osn := suffix[0]
-					arch := suffix[1]
-
-					// Prefill the map to avoid a panic.
-					if _, ok := allReleases[osn]; !ok {
-						allReleases[osn] = map[string]release{}
-					}
+		allReleases, err := addReleaseAssets(ctx, client, &rl, r, m, isLatest)
+		if err != nil {
+			return nil, err
+		}
+		if isLatest {
+			rl.Platforms = allReleases
+		}
 
-					tr, ok := allReleases[osn][arch]
-					if !ok {
-						allReleases[osn][arch] = release{
-							BinaryURL:  asset.GetBrowserDownloadURL(),
-							BinaryName: asset.GetName(),
-							Repository: repo,
-						}
-					} else {
-						tr.BinaryURL = asset.GetBrowserDownloadURL()
-						tr.BinaryName = asset.GetName()
-						allReleases[osn][arch] = tr
-					}
-				}
+		if !shouldUpdateBody(s, i, r) {
+			continue
+		}
+		bu := bodyUpdate{
+			repo:      repo,
+			release:   r,
+			platforms: allReleases,
+			changelog: s.changelog,
+			dryRun:    updateReleaseBody == updateModeDryRun,
+		}
+		bu.previous = previousRelease(releases, i)
+		if bu.dryRun {
+			// Nothing is written in a dry run, so we can wait for it.
+			uctx := withLogFields(ctx, logrus.Fields{logFieldTag: r.GetTagName()})
+			if _, _, err := updateRelease(uctx, client, bu); err != nil {
+				logFor(uctx).Warn(err)
 			}
+		} else if updater != nil {
+			updater.enqueue(bu)
+		}
+	}
 
-			if strings.HasSuffix(asset.GetName(), ".sha256") {
-				// We know we are on a sha256sum.
-				suffix := strings.SplitN(strings.TrimSuffix(strings.TrimPrefix(asset.GetName(), repo.GetName()+"-"), ".sha256"), "-", 2)
-				if len(suffix) == 2 {
-					// Add this to our overall releases map.
-					osn := suffix[0]
-					arch := suffix[1]
-
-					c, err := getReleaseAssetContent(ctx, client, repo, asset.GetID())
-					if err != nil {
-						return nil, err
-					}
+	rl.Warnings = lintRelease(rl, s)
 
-					// Prefill the map to avoid a panic.
-					if _, ok := allReleases[osn]; !ok {
-						allReleases[osn] = map[string]release{}
-					}
+	return &rl, nil
+}
 
-					tr, ok := allReleases[osn][arch]
-					if !ok {
-						allReleases[osn][arch] = release{
-							BinarySHA256: c,
-							Repository:   repo,
-						}
-					} else {
-						tr.BinarySHA256 = c
-						allReleases[osn][arch] = tr
-					}
-				}
+// addReleaseAssets adds the assets of the release r to rl and returns its
+// binaries keyed by os -> arch, with the checksums from their .sha256
+// assets. If the release is the latest, the linux/amd64 binary and its
+// checksums are set on rl too.
+func addReleaseAssets(ctx context.Context, client *github.Client, rl *release, r *github.RepositoryRelease, m *binaryMatcher, latest bool) (map[string]map[string]release, error) {
+	repo := rl.Repository
+	allReleases := map[string]map[string]release{}
+
+	// Iterate over the assets.
+	for _, asset := range r.Assets {
+		rl.BinaryDownloadCount += asset.GetDownloadCount()
+
+		ra := releaseAsset{
+			Tag:           r.GetTagName(),
+			Name:          asset.GetName(),
+			Size:          asset.GetSize(),
+			DownloadCount: asset.GetDownloadCount(),
+			CreatedAt:     asset.GetCreatedAt().Time,
+		}
+		ra.OS, ra.Arch = m.platform(asset.GetName())
+		rl.Assets = append(rl.Assets, ra)
+
+		if osn, arch := ra.OS, ra.Arch; osn != "" {
+			// We know we are on a binary and not a hashsum.
+			// Prefill the map to avoid a panic.
+			if _, ok := allReleases[osn]; !ok {
+				allReleases[osn] = map[string]release{}
 			}
 
-			if isLatest && strings.HasSuffix(asset.GetName(), arch) {
-				rl.BinaryURL = asset.GetBrowserDownloadURL()
-				rl.BinaryName = asset.GetName()
-				rl.BinarySince = units.HumanDuration(time.Since(asset.GetCreatedAt().Time))
+			tr, ok := allReleases[osn][arch]
+			if !ok {
+				allReleases[osn][arch] = release{
+					BinaryURL:  asset.GetBrowserDownloadURL(),
+					BinaryName: asset.GetName(),
+					Repository: repo,
+				}
+			} else {
+				tr.BinaryURL = asset.GetBrowserDownloadURL()
+				tr.BinaryName = asset.GetName()
+				allReleases[osn][arch] = tr
 			}
+		}
 
-			if isLatest && strings.HasSuffix(asset.GetName(), arch+".sha256") {
-				c, err := getReleaseAssetContent(ctx, client, repo, asset.GetID())
+		if strings.HasSuffix(asset.GetName(), ".sha256") {
+			// We know we are on a sha256sum.
+			if osn, arch := m.platform(strings.TrimSuffix(asset.GetName(), ".sha256")); osn != "" {
+				// Add this to our overall releases map.
+				c, err := getReleaseAssetContent(ctx, client, repo, &asset)
 				if err != nil {
 					return nil, err
 				}
-				rl.BinarySHA256 = c
-			}
 
-			if isLatest && strings.HasSuffix(asset.GetName(), arch+".md5") {
-				c, err := getReleaseAssetContent(ctx, client, repo, asset.GetID())
-				if err != nil {
-					return nil, err
+				// Prefill the map to avoid a panic.
+				if _, ok := allReleases[osn]; !ok {
+					allReleases[osn] = map[string]release{}
+				}
+
+				tr, ok := allReleases[osn][arch]
+				if !ok {
+					allReleases[osn][arch] = release{
+						BinarySHA256: c,
+						Repository:   repo,
+					}
+				} else {
+					tr.BinarySHA256 = c
+					allReleases[osn][arch] = tr
 				}
-				rl.BinaryMD5 = c
 			}
 		}
 
-		if updateReleaseBody {
-			// Do this in a go routine we don't really care if it fails.
-			go func(repo *github.Repository, r *github.RepositoryRelease, releases map[string]map[string]release) {
-				if err := updateRelease(ctx, client, repo, r, releases); err != nil {
-					logrus.Warn(err)
-				}
-			}(repo, r, allReleases)
+		if latest && ra.OS == "linux" && ra.Arch == "amd64" {
+			rl.BinaryURL = asset.GetBrowserDownloadURL()
+			rl.BinaryName = asset.GetName()
+			rl.BinarySince = units.HumanDuration(time.Since(asset.GetCreatedAt().Time))
+		}
+
+		if latest && asset.GetName() == m.name("linux", "amd64")+".sha256" {
+			c, err := getReleaseAssetContent(ctx, client, repo, &asset)
+			if err != nil {
+				return nil, err
+			}
+			rl.BinarySHA256 = c
+		}
+
+		if latest && asset.GetName() == m.name("linux", "amd64")+".md5" {
+			c, err := getReleaseAssetContent(ctx, client, repo, &asset)
+			if err != nil {
+				return nil, err
+			}
+			rl.BinaryMD5 = c
 		}
 	}
 
-	return &rl, nil
+	return allReleases, nil
 }
 
-func updateRelease(ctx context.Context, client *github.Client, repo *github.Repository, r *github.RepositoryRelease, releases map[string]map[string]release) error {
+// updateRelease updates the body of the release, it returns the release as
+// it is now and if it needed to be updated. The release of bu is shared with
+// the served release data, so it is never modified.
+func updateRelease(ctx context.Context, client *github.Client, bu bodyUpdate) (*github.RepositoryRelease, bool, error) {
 	var (
-		b bytes.Buffer
+		b    bytes.Buffer
+		repo = bu.repo
+		r    = bu.release
 	)
 
-	// Parse the template.
-	funcMap := template.FuncMap{
-		"ToUpper": strings.ToUpper,
+	// Writing the body without the changelog would remove the one that is
+	// already there, and the release would look up to date afterwards, so
+	// it is retried on the next refresh instead.
+	c, err := getChangelog(ctx, client, repo, bu.changelog, bu.previous, r)
+	if err != nil {
+		return nil, false, fmt.Errorf("not updating release for %s -> %s: %v", repo.GetFullName(), r.GetTagName(), err)
 	}
-	t := template.Must(template.New("").Funcs(funcMap).Delims("<<", ">>").Parse(releaseTmpl))
-	w := io.Writer(&b)
 
 	// Execute the template.
-	if err := t.Execute(w, releases); err != nil {
-		return err
+	w := io.Writer(&b)
+	if err := currentTemplates().releaseBody.Execute(w, releaseBody{
+		Repository: repo,
+		Release:    r,
+		Platforms:  bu.platforms,
+		Changelog:  c,
+	}); err != nil {
+		return nil, false, err
 	}
 
-	s := b.String()
+	// Only replace the generated section so hand-written release notes
+	// are kept.
+	s, err := mergeReleaseBody(r.GetBody(), b.String())
+	if err != nil {
+		return nil, false, fmt.Errorf("not updating release for %s -> %s: %v", repo.GetFullName(), r.GetTagName(), err)
+	}
 
 	// Check if the body already matches the body we need.
-	if *r.Body == s && *r.Name == *r.TagName {
+	if r.GetBody() == s && r.GetName() == r.GetTagName() {
 		// Return early here.
-		logrus.Debugf("Release is already updated for %s -> %s...", repo.GetFullName(), r.GetTagName())
-		return nil
+		logFor(ctx).Debug("Release is already updated.")
+		if bu.dryRun {
+			dryRunDiffs.add(repo.GetFullName(), r.GetTagName(), "")
+		}
+		return r, false, nil
 	}
 
-	r.Body = &s
-	r.Name = r.TagName
+	if bu.dryRun {
+		diff := unifiedDiff(r.GetBody(), s, "a/"+r.GetTagName(), "b/"+r.GetTagName())
+		if r.GetName() != r.GetTagName() {
+			diff = fmt.Sprintf("name: %q -> %q\n", r.GetName(), r.GetTagName()) + diff
+		}
+		logFor(ctx).Infof("Dry run: would update release:\n%s", diff)
+		dryRunDiffs.add(repo.GetFullName(), r.GetTagName(), diff)
+		return r, true, nil
+	}
 
 	// Send the new body to GitHub to update the release.
-	logrus.Debugf("Updating release for %s -> %s...", repo.GetFullName(), r.GetTagName())
-	_, resp, err := client.Repositories.EditRelease(ctx, repo.GetOwner().GetLogin(), repo.GetName(), r.GetID(), r)
-	if resp.StatusCode == http.StatusForbidden {
-		return nil
+	logFor(ctx).Debug("Updating release...")
+	_, resp, err := client.Repositories.EditRelease(ctx, repo.GetOwner().GetLogin(), repo.GetName(), r.GetID(), &github.RepositoryRelease{
+		Name: r.TagName,
+		Body: &s,
+	})
+	if resp != nil && resp.StatusCode == http.StatusForbidden {
+		return nil, false, fmt.Errorf("updating release for %s -> %s failed: the token or GitHub App is not allowed to edit releases", repo.GetFullName(), r.GetTagName())
 	}
-	return err
-}
-
-func getReleaseAssetContent(ctx context.Context, client *github.Client, repo *github.Repository, id int64) (string, error) {
-	body, redirectURL, err := client.Repositories.DownloadReleaseAsset(ctx, repo.GetOwner().GetLogin(), repo.GetName(), id)
 	if err != nil {
-		return "", err
+		return nil, false, fmt.Errorf("updating release for %s -> %s failed: %v", repo.GetFullName(), r.GetTagName(), err)
 	}
-	if body == nil && len(redirectURL) > 0 {
-		resp, err := http.Get(redirectURL)
-		if err != nil {
-			return "", fmt.Errorf("getting redirect url %s failed: %v", redirectURL, err)
-		}
-		body = resp.Body
-	}
-	if body == nil {
-		return "", errors.New("body for asset was nil")
+
+	updated := *r
+	updated.Body = &s
+	updated.Name = r.TagName
+	return &updated, true, nil
+}
+
+// getReleaseAssetContent returns the first word of a checksum asset. The
+// content is cached, since assets do not change once uploaded.
+func getReleaseAssetContent(ctx context.Context, client *github.Client, repo *github.Repository, asset *github.ReleaseAsset) (_ string, err error) {
+	id := asset.GetID()
+	ctx, span := startSpan(ctx, "getReleaseAssetContent", spanKindInternal,
+		attr("repository", repo.GetFullName()),
+		attr("asset.id", id),
+	)
+	defer func() {
+		span.fail(err)
+		span.finish()
+	}()
+	ctx = withLogFields(ctx, logrus.Fields{logFieldAsset: id})
+
+	if c, ok := checksums.get(asset); ok {
+		span.set("cached", true)
+		return c, nil
 	}
-	defer body.Close()
+	span.set("cached", false)
 
-	b, err := ioutil.ReadAll(body)
+	b, err := downloadAsset(ctx, client, repo, id)
 	if err != nil {
 		return "", err
 	}
 
-	return strings.Split(string(b), " ")[0], nil
+	c := strings.Split(string(b), " ")[0]
+	checksums.set(asset, c)
+	return c, nil
 }
 
 func in(a stringSlice, s string) bool {
@@ -496,3 +1243,18 @@ func in(a stringSlice, s string) bool {
 	}
 	return false
 }
+
+// waitFor waits for fn to return, or gives up when ctx is done.
+func waitFor(ctx context.Context, what string, fn func()) {
+	done := make(chan struct{})
+	go func() {
+		fn()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		logrus.Warnf("timed out waiting for %s", what)
+	}
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// writeManifests writes the package manifests for every release to dir.
+// Repositories without the binaries a manifest needs are skipped, and so
+// are the manifest files two repositories would both be written to.
+func writeManifests(ctx context.Context, dir string, releases []release) error {
+	var (
+		files = map[string][]byte{}
+		// owners maps the manifest files to the repositories they are for.
+		owners = map[string][]string{}
+	)
+	for _, r := range releases {
+		log := logFor(ctx).WithFields(logrus.Fields{
+			logFieldRepo: r.Repository.GetFullName(),
+			logFieldTag:  r.Release.GetTagName(),
+		})
+		add := func(p string, b []byte) {
+			owners[p] = append(owners[p], r.Repository.GetFullName())
+			files[p] = b
+		}
+
+		// Scoop buckets are flat, so the manifest is named after the
+		// repository alone.
+		if b, err := scoopManifestBytes(r); err == nil {
+			add(filepath.Join("scoop", "bucket", r.Repository.GetName()+".json"), b)
+		} else {
+			log.Debugf("Skipping scoop manifest: %v", err)
+		}
+
+		if m, err := newWingetManifest(r); err == nil {
+			wf, err := m.files()
+			if err != nil {
+				return err
+			}
+			for p, b := range wf {
+				add(filepath.Join("winget", p), b)
+			}
+		} else {
+			log.Debugf("Skipping winget manifest: %v", err)
+		}
+
+		if b, err := pkgbuildBytes(r); err == nil {
+			add(filepath.Join("aur", r.Repository.GetOwner().GetLogin(), r.Repository.GetName()+"-bin", "PKGBUILD"), b)
+		} else {
+			log.Debugf("Skipping PKGBUILD: %v", err)
+		}
+
+		if b, err := nixBytes(r); err == nil {
+			add(filepath.Join("nix", r.Repository.GetOwner().GetLogin(), r.Repository.GetName()+".nix"), b)
+		} else {
+			log.Debugf("Skipping nix expression: %v", err)
+		}
+
+	}
+
+	for p, b := range files {
+		if len(owners[p]) > 1 {
+			// We cannot tell which repository the file should be for.
+			logFor(ctx).WithField("path", p).Warnf("Skipping manifest, it would be written for %s", strings.Join(owners[p], " and "))
+			continue
+		}
+		if err := writeFile(filepath.Join(dir, p), b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeFile atomically writes b to the file p, creating any parent
+// directories.
+func writeFile(p string, b []byte) error {
+	if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		return err
+	}
+
+	f, err := ioutil.TempFile(filepath.Dir(p), "."+filepath.Base(p))
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(b); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	if err := os.Chmod(f.Name(), 0644); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+
+	return os.Rename(f.Name(), p)
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/github"
+)
+
+var update = flag.Bool("update", false, "update the golden files in testdata")
+
+// checkGolden compares got with the golden file testdata/name, or writes
+// it with -update.
+func checkGolden(t *testing.T, name string, got []byte) {
+	t.Helper()
+
+	p := filepath.Join("testdata", name)
+	if *update {
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(p, got, 0644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	want, err := ioutil.ReadFile(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("%s: got\n%s\nwant\n%s", name, got, want)
+	}
+}
+
+// testManifestRelease returns the latest release of repo with a binary for
+// each of the platforms, the ones suffixed with ! have no checksum.
+func testManifestRelease(repo, tag string, platforms ...string) release {
+	owner, name := strings.Split(repo, "/")[0], strings.Split(repo, "/")[1]
+	r := release{
+		Repository: &github.Repository{
+			Name:        github.String(name),
+			FullName:    github.String(repo),
+			Owner:       &github.User{Login: github.String(owner)},
+			Description: github.String(`Builds "images" with ${HOME}'s config`),
+			HTMLURL:     github.String("https://github.com/" + repo),
+			License:     &github.License{SPDXID: github.String("MIT")},
+		},
+		Release: &github.RepositoryRelease{
+			TagName:     github.String(tag),
+			PublishedAt: &github.Timestamp{Time: time.Date(2020, 10, 1, 12, 0, 0, 0, time.UTC)},
+		},
+		Platforms: map[string]map[string]release{},
+	}
+	for _, p := range platforms {
+		noSum := strings.HasSuffix(p, "!")
+		s := strings.SplitN(strings.TrimSuffix(p, "!"), "/", 2)
+		b := release{
+			BinaryName: name + "-" + s[0] + "-" + s[1],
+		}
+		b.BinaryURL = "https://github.com/" + repo + "/releases/download/" + tag + "/" + b.BinaryName
+		if !noSum {
+			b.BinarySHA256 = fmt.Sprintf("%x", sha256.Sum256([]byte(b.BinaryName)))
+		}
+		if r.Platforms[s[0]] == nil {
+			r.Platforms[s[0]] = map[string]release{}
+		}
+		r.Platforms[s[0]][s[1]] = b
+	}
+	return r
+}
+
+func TestScoopManifest(t *testing.T) {
+	b, err := scoopManifestBytes(testManifestRelease("genuinetools/img", "v0.5.11", "windows/amd64", "windows/386!", "windows/arm", "linux/amd64"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkGolden(t, "scoop.json", b)
+
+	if _, err := scoopManifestBytes(testManifestRelease("genuinetools/img", "v0.5.11", "linux/amd64")); err == nil {
+		t.Error("expected an error for a release without windows binaries")
+	}
+}
+
+func TestWingetManifest(t *testing.T) {
+	r := testManifestRelease("genuinetools/img", "v0.5.11", "windows/amd64", "windows/386!", "windows/arm64", "linux/amd64")
+	b, err := wingetManifestBytes(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkGolden(t, "winget.yaml", b)
+
+	m, err := newWingetManifest(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	files, err := m.files()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for p := range files {
+		names = append(names, p)
+	}
+	sort.Strings(names)
+	want := []string{
+		"manifests/g/genuinetools/img/0.5.11/genuinetools.img.installer.yaml",
+		"manifests/g/genuinetools/img/0.5.11/genuinetools.img.locale.en-US.yaml",
+		"manifests/g/genuinetools/img/0.5.11/genuinetools.img.yaml",
+	}
+	if strings.Join(names, " ") != strings.Join(want, " ") {
+		t.Errorf("got files %v, want %v", names, want)
+	}
+	for _, p := range names {
+		checkGolden(t, filepath.Join("winget", filepath.Base(p)), files[p])
+	}
+
+	// WinGet needs the checksums.
+	if _, err := newWingetManifest(testManifestRelease("genuinetools/img", "v0.5.11", "windows/amd64!")); err == nil {
+		t.Error("expected an error for a release without windows checksums")
+	}
+}
+
+func TestWriteManifests(t *testing.T) {
+	dir := t.TempDir()
+	releases := []release{
+		testManifestRelease("genuinetools/img", "v0.5.11", "windows/amd64", "linux/amd64"),
+		testManifestRelease("jessfraz/img", "v1.0.0", "windows/amd64", "linux/amd64"),
+		testManifestRelease("genuinetools/reg", "v0.16.1", "windows/amd64"),
+		testManifestRelease("genuinetools/pepper", "v0.1.0", "darwin/amd64"),
+	}
+	if err := writeManifests(context.Background(), dir, releases); err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	filepath.Walk(dir, func(p string, fi os.FileInfo, err error) error {
+		if err == nil && !fi.IsDir() {
+			rel, _ := filepath.Rel(dir, p)
+			got = append(got, rel)
+		}
+		return err
+	})
+	sort.Strings(got)
+
+	// The Scoop manifests of the two img repositories collide, the other
+	// manifests are still written.
+	want := []string{
+		"aur/genuinetools/img-bin/PKGBUILD",
+		"aur/jessfraz/img-bin/PKGBUILD",
+		"nix/genuinetools/img.nix",
+		"nix/genuinetools/pepper.nix",
+		"nix/jessfraz/img.nix",
+		"scoop/bucket/reg.json",
+		"winget/manifests/g/genuinetools/img/0.5.11/genuinetools.img.installer.yaml",
+		"winget/manifests/g/genuinetools/img/0.5.11/genuinetools.img.locale.en-US.yaml",
+		"winget/manifests/g/genuinetools/img/0.5.11/genuinetools.img.yaml",
+		"winget/manifests/g/genuinetools/reg/0.16.1/genuinetools.reg.installer.yaml",
+		"winget/manifests/g/genuinetools/reg/0.16.1/genuinetools.reg.locale.en-US.yaml",
+		"winget/manifests/g/genuinetools/reg/0.16.1/genuinetools.reg.yaml",
+		"winget/manifests/j/jessfraz/img/1.0.0/jessfraz.img.installer.yaml",
+		"winget/manifests/j/jessfraz/img/1.0.0/jessfraz.img.locale.en-US.yaml",
+		"winget/manifests/j/jessfraz/img/1.0.0/jessfraz.img.yaml",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got files\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
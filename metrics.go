@@ -0,0 +1,377 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gregjones/httpcache"
+	"github.com/sirupsen/logrus"
+)
+
+// The metrics are written in the Prometheus text exposition format.
+
+var (
+	metricRefreshDuration = newHistogram("releases_refresh_duration_seconds", "Duration of the refreshes of the release data.",
+		[]float64{1, 5, 10, 30, 60, 120, 300, 600})
+	metricRefreshes    = newCounter("releases_refreshes_total", "Refreshes of the release data by result.", "result")
+	metricLastRefresh  = newGauge("releases_last_successful_refresh_timestamp_seconds", "Time of the last successful refresh.")
+	metricReposScanned = newGauge("releases_repositories_scanned", "Repositories scanned in the last refresh.")
+	metricReleases     = newGauge("releases_releases_found", "Repositories with a release in the last refresh.")
+	metricDownloads    = newGauge("releases_downloads", "Downloads of the assets of all releases of a repository.", "repository")
+
+	metricAPIRequests        = newCounter("releases_github_api_requests_total", "GitHub API requests by endpoint and status.", "endpoint", "status")
+	metricRateLimit          = newGauge("releases_github_rate_limit", "GitHub API requests allowed in a rate limit window.")
+	metricRateLimitRemaining = newGauge("releases_github_rate_limit_remaining", "Remaining GitHub API requests in the current rate limit window.")
+	metricRateLimitReset     = newGauge("releases_github_rate_limit_reset_timestamp_seconds", "Time the GitHub API rate limit window resets.")
+	metricCacheRequests      = newCounter("releases_http_cache_requests_total", "GitHub API requests served from the HTTP cache or not.", "result")
+	metricCacheHitRatio      = newGauge("releases_http_cache_hit_ratio", "Ratio of GitHub API requests served from the HTTP cache.")
+	metricCacheEntries       = newGauge("releases_http_cache_entries", "Responses in the HTTP cache.")
+	metricCacheSize          = newGauge("releases_http_cache_size_bytes", "Size of the responses in the HTTP cache.")
+	metricCacheEvictions     = newCounter("releases_http_cache_evictions_total", "Responses evicted from the HTTP cache because it was full.")
+
+	metricBodyUpdates       = newCounter("releases_body_updates_attempted_total", "Release body updates attempted.")
+	metricBodyUpdatesFailed = newCounter("releases_body_updates_failed_total", "Release body updates that failed.")
+
+	metricHTTPRequests = newCounter("releases_http_requests_total", "HTTP requests served by route and status code.", "route", "code")
+	metricHTTPDuration = newHistogram("releases_http_request_duration_seconds", "Duration of the HTTP requests served by route.",
+		[]float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5}, "route")
+)
+
+// allMetrics are written by the metrics handler in the order they were
+// created.
+var allMetrics []interface {
+	write(w io.Writer)
+}
+
+// metricVec is a counter or gauge with optional labels.
+type metricVec struct {
+	name   string
+	help   string
+	kind   string
+	labels []string
+
+	mu     sync.Mutex
+	values map[string]float64
+}
+
+func newMetricVec(kind, name, help string, labels []string) *metricVec {
+	m := &metricVec{
+		name:   name,
+		help:   help,
+		kind:   kind,
+		labels: labels,
+		values: map[string]float64{},
+	}
+	allMetrics = append(allMetrics, m)
+	return m
+}
+
+func newCounter(name, help string, labels ...string) *metricVec {
+	return newMetricVec("counter", name, help, labels)
+}
+
+func newGauge(name, help string, labels ...string) *metricVec {
+	return newMetricVec("gauge", name, help, labels)
+}
+
+func (m *metricVec) add(v float64, labelValues ...string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.values[labelKey(labelValues)] += v
+}
+
+func (m *metricVec) inc(labelValues ...string) {
+	m.add(1, labelValues...)
+}
+
+func (m *metricVec) set(v float64, labelValues ...string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.values[labelKey(labelValues)] = v
+}
+
+func (m *metricVec) get(labelValues ...string) float64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.values[labelKey(labelValues)]
+}
+
+// reset removes all label values, so series that went away are not
+// reported anymore.
+func (m *metricVec) reset() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.values = map[string]float64{}
+}
+
+func (m *metricVec) write(w io.Writer) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", m.name, m.help, m.name, m.kind)
+	if len(m.labels) == 0 && len(m.values) == 0 {
+		fmt.Fprintf(w, "%s 0\n", m.name)
+		return
+	}
+	for _, key := range sortedKeys(m.values) {
+		fmt.Fprintf(w, "%s%s %s\n", m.name, formatLabels(m.labels, key, "", ""), formatFloat(m.values[key]))
+	}
+}
+
+// histogramVec is a histogram with optional labels.
+type histogramVec struct {
+	name    string
+	help    string
+	labels  []string
+	buckets []float64
+
+	mu     sync.Mutex
+	series map[string]*histogram
+}
+
+type histogram struct {
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+func newHistogram(name, help string, buckets []float64, labels ...string) *histogramVec {
+	h := &histogramVec{
+		name:    name,
+		help:    help,
+		labels:  labels,
+		buckets: buckets,
+		series:  map[string]*histogram{},
+	}
+	allMetrics = append(allMetrics, h)
+	return h
+}
+
+func (h *histogramVec) observe(v float64, labelValues ...string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	key := labelKey(labelValues)
+	s, ok := h.series[key]
+	if !ok {
+		s = &histogram{counts: make([]uint64, len(h.buckets))}
+		h.series[key] = s
+	}
+	for i, b := range h.buckets {
+		if v <= b {
+			s.counts[i]++
+		}
+	}
+	s.count++
+	s.sum += v
+}
+
+func (h *histogramVec) write(w io.Writer) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s histogram\n", h.name, h.help, h.name)
+	keys := make([]string, 0, len(h.series))
+	for key := range h.series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		s := h.series[key]
+		for i, b := range h.buckets {
+			fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, formatLabels(h.labels, key, "le", formatFloat(b)), s.counts[i])
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, formatLabels(h.labels, key, "le", "+Inf"), s.count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", h.name, formatLabels(h.labels, key, "", ""), formatFloat(s.sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", h.name, formatLabels(h.labels, key, "", ""), s.count)
+	}
+}
+
+// labelEscaper escapes label values.
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// labelKey joins label values into a map key.
+func labelKey(values []string) string {
+	return strings.Join(values, "\xff")
+}
+
+// formatLabels formats the label values in key as {name="value",...}, with
+// an extra label if extraName is set.
+func formatLabels(names []string, key, extraName, extraValue string) string {
+	var pairs []string
+	if len(names) > 0 {
+		for i, v := range strings.Split(key, "\xff") {
+			if i < len(names) {
+				pairs = append(pairs, names[i]+`="`+labelEscaper.Replace(v)+`"`)
+			}
+		}
+	}
+	if extraName != "" {
+		pairs = append(pairs, extraName+`="`+labelEscaper.Replace(extraValue)+`"`)
+	}
+	if len(pairs) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func formatFloat(v float64) string {
+	if math.IsInf(v, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+func sortedKeys(m map[string]float64) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// metricsHandler serves all metrics.
+func metricsHandler(w http.ResponseWriter, req *http.Request) {
+	hits, misses := metricCacheRequests.get("hit"), metricCacheRequests.get("miss")
+	if hits+misses > 0 {
+		metricCacheHitRatio.set(hits / (hits + misses))
+	}
+	cs := httpCache.stats()
+	metricCacheEntries.set(float64(cs.Entries))
+	metricCacheSize.set(float64(cs.Size))
+
+	var b bytes.Buffer
+	for _, m := range allMetrics {
+		m.write(&b)
+	}
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	w.Write(b.Bytes())
+}
+
+// recordRefresh records the metrics of a refresh that started at start.
+func recordRefresh(start time.Time, releases []release, err error) {
+	metricRefreshDuration.observe(time.Since(start).Seconds())
+	if err != nil {
+		metricRefreshes.inc("error")
+		return
+	}
+	metricRefreshes.inc("success")
+	metricLastRefresh.set(float64(time.Now().Unix()))
+	metricReleases.set(float64(len(releases)))
+
+	metricDownloads.reset()
+	for _, r := range releases {
+		metricDownloads.set(float64(r.BinaryDownloadCount), r.Repository.GetFullName())
+	}
+}
+
+// metricsTransport counts the GitHub API requests by endpoint and status,
+// whether they were served from the HTTP cache and the rate limit.
+type metricsTransport struct {
+	base http.RoundTripper
+}
+
+func (t *metricsTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	resp, err := t.base.RoundTrip(req)
+	endpoint := apiEndpoint(req.URL.Path)
+	log := logFor(req.Context()).WithFields(logrus.Fields{
+		logFieldEndpoint: req.Method + " " + endpoint,
+		logFieldDuration: time.Since(start).String(),
+	})
+	if err != nil {
+		metricAPIRequests.inc(endpoint, "error")
+		log.WithError(err).Debug("GitHub API request failed")
+		return resp, err
+	}
+	metricAPIRequests.inc(endpoint, strconv.Itoa(resp.StatusCode))
+
+	if resp.Header.Get(httpcache.XFromCache) != "" {
+		metricCacheRequests.inc("hit")
+		log.WithFields(logrus.Fields{"status": resp.StatusCode, "cache": "hit"}).Debug("GitHub API request")
+		// The rate limit headers of a cached response are stale.
+		return resp, nil
+	}
+	metricCacheRequests.inc("miss")
+	log.WithFields(logrus.Fields{"status": resp.StatusCode, "cache": "miss"}).Debug("GitHub API request")
+
+	if v, err := strconv.ParseFloat(resp.Header.Get("X-RateLimit-Limit"), 64); err == nil {
+		metricRateLimit.set(v)
+	}
+	if v, err := strconv.ParseFloat(resp.Header.Get("X-RateLimit-Remaining"), 64); err == nil {
+		metricRateLimitRemaining.set(v)
+	}
+	if v, err := strconv.ParseFloat(resp.Header.Get("X-RateLimit-Reset"), 64); err == nil {
+		metricRateLimitReset.set(v)
+	}
+	return resp, nil
+}
+
+// apiEndpoint returns the path of a GitHub API request with the owner,
+// repository, ids and tags replaced by placeholders, so it can be used as a
+// label.
+func apiEndpoint(p string) string {
+	p = strings.TrimPrefix(p, "/api/v3")
+	parts := strings.Split(strings.Trim(p, "/"), "/")
+	for i := 1; i < len(parts); i++ {
+		switch {
+		case i == 1 && (parts[0] == "repos" || parts[0] == "users" || parts[0] == "orgs"):
+			parts[i] = ":owner"
+		case i == 2 && parts[0] == "repos":
+			parts[i] = ":repo"
+		case parts[i-1] == "compare":
+			parts[i] = ":basehead"
+		case parts[i-1] == "tags":
+			parts[i] = ":tag"
+		case parts[i] != "" && strings.Trim(parts[i], "0123456789") == "":
+			parts[i] = ":id"
+		}
+	}
+	return "/" + strings.Join(parts, "/")
+}
+
+// instrument counts the requests and their duration for every route of
+// mux, and logs them.
+func instrument(mux *http.ServeMux) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		start := time.Now()
+		sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+		mux.ServeHTTP(sw, req)
+
+		_, route := mux.Handler(req)
+		if route == "" {
+			route = "unknown"
+		}
+		d := time.Since(start)
+		metricHTTPRequests.inc(route, strconv.Itoa(sw.status))
+		metricHTTPDuration.observe(d.Seconds(), route)
+		accessLog(req, sw.status, sw.size, d)
+	})
+}
+
+// statusWriter remembers the status code and size of a response.
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+	size   int
+}
+
+func (w *statusWriter) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *statusWriter) Write(b []byte) (int, error) {
+	n, err := w.ResponseWriter.Write(b)
+	w.size += n
+	return n, err
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"text/template"
+)
+
+const (
+	nixTmpl = `# Generated by https://github.com/genuinetools/releases, do not edit.
+{ lib, stdenv, fetchurl }:
+
+let
+  sources = {
+{{- range .Sources}}
+    {{.System}} = fetchurl {
+      url = {{quote .URL}};
+      hash = {{quote .Hash}};
+    };
+{{- end}}
+  };
+in
+stdenv.mkDerivation {
+  pname = {{quote .Name}};
+  version = {{quote .Version}};
+
+  src = sources.${stdenv.hostPlatform.system} or (throw "unsupported system: ${stdenv.hostPlatform.system}");
+
+  dontUnpack = true;
+
+  installPhase = ''
+    runHook preInstall
+    install -Dm755 $src $out/bin/{{.Name}}
+    runHook postInstall
+  '';
+
+  meta = {
+    description = {{quote .Description}};
+    homepage = {{quote .Homepage}};
+{{- if .License}}
+    license = lib.getLicenseFromSpdxId {{quote .License}};
+{{- end}}
+    platforms = [{{range .Sources}} {{quote .System}}{{end}} ];
+    mainProgram = {{quote .Name}};
+  };
+}
+`
+)
+
+// nixSystems maps GOOS/GOARCH to the system doubles used by Nix.
+var nixSystems = map[string]string{
+	"linux/amd64":  "x86_64-linux",
+	"linux/arm64":  "aarch64-linux",
+	"linux/arm":    "armv7l-linux",
+	"linux/386":    "i686-linux",
+	"darwin/amd64": "x86_64-darwin",
+	"darwin/arm64": "aarch64-darwin",
+}
+
+var nixTemplate = template.Must(template.New("").Funcs(template.FuncMap{
+	"quote": nixQuote,
+}).Parse(nixTmpl))
+
+// nixDerivation holds the data for rendering a Nix derivation.
+type nixDerivation struct {
+	Name        string
+	Version     string
+	Description string
+	Homepage    string
+	License     string
+	Sources     []nixSource
+}
+
+type nixSource struct {
+	System string
+	URL    string
+	Hash   string
+}
+
+// nixBytes returns a Nix derivation for the binaries of the latest release.
+func nixBytes(r release) ([]byte, error) {
+	if r.Release == nil {
+		return nil, fmt.Errorf("%s has no release", r.Repository.GetFullName())
+	}
+
+	d := nixDerivation{
+		Name:        r.Repository.GetName(),
+		Version:     strings.TrimPrefix(r.Release.GetTagName(), "v"),
+		Description: r.Repository.GetDescription(),
+		Homepage:    r.Repository.GetHTMLURL(),
+		License:     r.Repository.GetLicense().GetSPDXID(),
+	}
+
+	for _, platform := range []string{"linux/amd64", "linux/arm64", "linux/arm", "linux/386", "darwin/amd64", "darwin/arm64"} {
+		s := strings.SplitN(platform, "/", 2)
+		b, ok := r.Platforms[s[0]][s[1]]
+		if !ok || b.BinaryURL == "" || b.BinarySHA256 == "" {
+			continue
+		}
+
+		hash, err := sriHash(b.BinarySHA256)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", b.BinaryName, err)
+		}
+
+		d.Sources = append(d.Sources, nixSource{
+			System: nixSystems[platform],
+			URL:    b.BinaryURL,
+			Hash:   hash,
+		})
+	}
+
+	if len(d.Sources) < 1 {
+		return nil, fmt.Errorf("%s %s has no binaries with checksums", r.Repository.GetFullName(), r.Release.GetTagName())
+	}
+
+	var b bytes.Buffer
+	if err := nixTemplate.Execute(&b, d); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// sriHash converts a hex encoded sha256 sum to a subresource integrity
+// hash.
+func sriHash(sha256sum string) (string, error) {
+	b, err := hex.DecodeString(strings.TrimSpace(sha256sum))
+	if err != nil {
+		return "", fmt.Errorf("decoding sha256 %q failed: %v", sha256sum, err)
+	}
+	if len(b) != 32 {
+		return "", fmt.Errorf("sha256 %q has invalid length %d", sha256sum, len(b))
+	}
+	return "sha256-" + base64.StdEncoding.EncodeToString(b), nil
+}
+
+// nixQuote quotes s as a Nix string.
+func nixQuote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "${", `\${`, "\n", `\n`).Replace(s) + `"`
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNix(t *testing.T) {
+	b, err := nixBytes(testManifestRelease("genuinetools/img", "v0.5.11", "linux/amd64", "linux/arm64!", "darwin/arm64", "windows/amd64"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkGolden(t, "img.nix", b)
+
+	if _, err := nixBytes(testManifestRelease("genuinetools/img", "v0.5.11", "linux/amd64!", "windows/amd64")); err == nil {
+		t.Error("expected an error for a release without checksums")
+	}
+}
+
+func TestSRIHash(t *testing.T) {
+	testCases := []struct {
+		sum  string
+		want string
+		err  string
+	}{
+		{
+			// The sha256 of the empty string.
+			sum:  "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855",
+			want: "sha256-47DEQpj8HBSa+/TImW+5JCeuQeRkm5NMpJWZG3hSuFU=",
+		},
+		{
+			sum:  "E3B0C44298FC1C149AFBF4C8996FB92427AE41E4649B934CA495991B7852B855\n",
+			want: "sha256-47DEQpj8HBSa+/TImW+5JCeuQeRkm5NMpJWZG3hSuFU=",
+		},
+		{
+			sum: "e3b0c44298fc1c149afbf4c8996fb924",
+			err: "invalid length 16",
+		},
+		{
+			sum: "not hex",
+			err: "decoding sha256",
+		},
+	}
+
+	for _, tc := range testCases {
+		got, err := sriHash(tc.sum)
+		if tc.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tc.err) {
+				t.Errorf("%q: got error %v, want it to contain %q", tc.sum, err, tc.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: unexpected error: %v", tc.sum, err)
+			continue
+		}
+		if got != tc.want {
+			t.Errorf("%q: got %s, want %s", tc.sum, got, tc.want)
+		}
+	}
+}
+
+func TestNixQuote(t *testing.T) {
+	if got, want := nixQuote("a \"b\" \\ ${c}\n"), `"a \"b\" \\ \${c}\n"`; got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,426 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"html"
+	"net"
+	"net/http"
+	"net/smtp"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	notifyStateFile = "notified.json"
+
+	// notifyAttempts is how often sending a notification is tried before
+	// giving up, waiting notifyBackoff times two to the attempt in between.
+	notifyAttempts = 5
+	notifyBackoff  = 2 * time.Second
+
+	// notifyTimeout is how long a single attempt to send a notification may
+	// take.
+	notifyTimeout = 30 * time.Second
+)
+
+var notifyClient = &http.Client{Timeout: notifyTimeout}
+
+// releaseEvent is the notification sent for a new release.
+type releaseEvent struct {
+	Event       string    `json:"event"`
+	Repository  string    `json:"repository"`
+	Tag         string    `json:"tag"`
+	Name        string    `json:"name"`
+	URL         string    `json:"url"`
+	PublishedAt time.Time `json:"published_at"`
+}
+
+func (e releaseEvent) String() string {
+	return fmt.Sprintf("%s %s was released: %s", e.Repository, e.Tag, e.URL)
+}
+
+// destination is where notifications are sent. It is configured like
+// kind=url#repos=owner/repo,owner/*, where kind is webhook, slack, matrix or
+// smtp and the fragment optionally limits the repositories.
+type destination struct {
+	kind  string
+	url   *url.URL
+	repos []string
+}
+
+func parseDestination(s string) (*destination, error) {
+	kv := strings.SplitN(s, "=", 2)
+	if len(kv) != 2 {
+		return nil, fmt.Errorf("notification destination %q must be of the form kind=url", s)
+	}
+
+	u, err := url.Parse(kv[1])
+	if err != nil {
+		return nil, fmt.Errorf("parsing notification destination %q failed: %v", s, err)
+	}
+
+	d := &destination{kind: kv[0], url: u}
+	if u.Fragment != "" {
+		f, err := url.ParseQuery(u.Fragment)
+		if err != nil {
+			return nil, fmt.Errorf("parsing repository filter of notification destination %q failed: %v", s, err)
+		}
+		for _, r := range strings.Split(f.Get("repos"), ",") {
+			if r = strings.TrimSpace(r); r != "" {
+				d.repos = append(d.repos, r)
+			}
+		}
+		u.Fragment = ""
+	}
+
+	switch d.kind {
+	case "webhook", "slack":
+		if u.Scheme != "http" && u.Scheme != "https" {
+			return nil, fmt.Errorf("%s destination %q must be a http(s) url", d.kind, s)
+		}
+	case "matrix":
+		if u.Query().Get("room") == "" || u.Query().Get("token") == "" {
+			return nil, fmt.Errorf("matrix destination %q must set the room and token query parameters", s)
+		}
+	case "smtp":
+		if u.Host == "" || u.Query().Get("from") == "" || u.Query().Get("to") == "" {
+			return nil, fmt.Errorf("smtp destination %q must be of the form smtp://[user:pass@]host:port?from=...&to=...", s)
+		}
+	default:
+		return nil, fmt.Errorf("unknown notification destination kind %q, must be webhook, slack, matrix or smtp", d.kind)
+	}
+
+	return d, nil
+}
+
+// matches returns if notifications for repo should be sent to d.
+func (d *destination) matches(repo string) bool {
+	if len(d.repos) < 1 {
+		return true
+	}
+	for _, pattern := range d.repos {
+		if ok, _ := path.Match(strings.ToLower(pattern), strings.ToLower(repo)); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func (d *destination) String() string {
+	// Do not leak credentials into the logs.
+	return d.kind + "=" + d.url.Scheme + "://" + d.url.Host
+}
+
+// send sends the event to the destination, retrying with exponential
+// backoff on failure.
+func (d *destination) send(ctx context.Context, e releaseEvent) error {
+	var err error
+	for attempt := 0; attempt < notifyAttempts; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(notifyBackoff << uint(attempt-1)):
+			}
+		}
+
+		if err = d.sendOnce(ctx, e); err == nil {
+			return nil
+		}
+		logrus.WithFields(logrus.Fields{
+			logFieldRepo: e.Repository,
+			logFieldTag:  e.Tag,
+		}).Debugf("Sending notification to %s failed (attempt %d of %d): %v", d, attempt+1, notifyAttempts, err)
+	}
+	return err
+}
+
+func (d *destination) sendOnce(ctx context.Context, e releaseEvent) error {
+	switch d.kind {
+	case "webhook":
+		return postJSON(ctx, http.MethodPost, d.url.String(), nil, e)
+	case "slack":
+		// Slack ends the url of a link at the first |, the ones after it
+		// are part of the text.
+		return postJSON(ctx, http.MethodPost, d.url.String(), nil, map[string]string{
+			"text": fmt.Sprintf("<%s|%s %s> was released", slackEscape(strings.Replace(e.URL, "|", "%7C", -1)), slackEscape(e.Repository), slackEscape(e.Tag)),
+		})
+	case "matrix":
+		q := d.url.Query()
+		u := *d.url
+		u.RawQuery = ""
+		txn := strconv.FormatInt(time.Now().UnixNano(), 10)
+		u.Path = fmt.Sprintf("/_matrix/client/v3/rooms/%s/send/m.room.message/%s", q.Get("room"), txn)
+		u.RawPath = fmt.Sprintf("/_matrix/client/v3/rooms/%s/send/m.room.message/%s", url.PathEscape(q.Get("room")), txn)
+		return postJSON(ctx, http.MethodPut, u.String(), map[string]string{
+			"Authorization": "Bearer " + q.Get("token"),
+		}, map[string]string{
+			"msgtype":        "m.text",
+			"body":           e.String(),
+			"format":         "org.matrix.custom.html",
+			"formatted_body": fmt.Sprintf(`<a href="%s">%s %s</a> was released`, html.EscapeString(e.URL), html.EscapeString(e.Repository), html.EscapeString(e.Tag)),
+		})
+	case "smtp":
+		return sendMail(ctx, d.url, e)
+	}
+	return fmt.Errorf("unknown notification destination kind %q", d.kind)
+}
+
+// slackEscape escapes the characters Slack uses for formatting.
+// See: https://api.slack.com/reference/surfaces/formatting#escaping
+func slackEscape(s string) string {
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace(s)
+}
+
+func postJSON(ctx context.Context, method, u string, headers map[string]string, v interface{}) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(method, u, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := notifyClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("%s %s returned %s", method, req.URL.Host, resp.Status)
+	}
+	return nil
+}
+
+// sendMail sends the event as an email. It gives up after notifyTimeout or
+// when ctx is done, even if the server stops responding.
+func sendMail(ctx context.Context, u *url.URL, e releaseEvent) error {
+	q := u.Query()
+	from := q.Get("from")
+	to := strings.Split(q.Get("to"), ",")
+
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", from)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(to, ", "))
+	fmt.Fprintf(&msg, "Subject: %s %s was released\r\n", e.Repository, e.Tag)
+	fmt.Fprintf(&msg, "Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	fmt.Fprintf(&msg, "%s\r\n", e)
+
+	ctx, cancel := context.WithTimeout(ctx, notifyTimeout)
+	defer cancel()
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", u.Host)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	deadline, _ := ctx.Deadline()
+	conn.SetDeadline(deadline)
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.Close()
+		case <-done:
+		}
+	}()
+
+	c, err := smtp.NewClient(conn, u.Hostname())
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	// Like smtp.SendMail, use TLS if the server supports it.
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err := c.StartTLS(&tls.Config{ServerName: u.Hostname()}); err != nil {
+			return err
+		}
+	}
+	if u.User != nil {
+		pass, _ := u.User.Password()
+		if err := c.Auth(smtp.PlainAuth("", u.User.Username(), pass, u.Hostname())); err != nil {
+			return err
+		}
+	}
+	if err := c.Mail(from); err != nil {
+		return err
+	}
+	for _, addr := range to {
+		if err := c.Rcpt(addr); err != nil {
+			return err
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(msg.Bytes()); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
+
+// notifier sends notifications for releases it has not seen before.
+type notifier struct {
+	destinations []*destination
+	// ctx is used to send the notifications, it is not cancelled when we
+	// shut down so the notifications in progress can finish.
+	ctx context.Context
+	wg  sync.WaitGroup
+
+	mu   sync.Mutex
+	seen map[int64]bool
+	// checked is when we last checked for new releases, zero if we did
+	// not since we started. On the first check only the releases of the
+	// repositories we have seen releases of before are notified, so we do
+	// not notify about every existing release.
+	checked time.Time
+}
+
+func newNotifier(ctx context.Context, destinations []*destination) (*notifier, error) {
+	n := &notifier{
+		destinations: destinations,
+		ctx:          ctx,
+	}
+	if err := loadState(notifyStateFile, &n.seen); err != nil {
+		return nil, fmt.Errorf("loading %s failed: %v", notifyStateFile, err)
+	}
+	if n.seen == nil {
+		n.seen = map[int64]bool{}
+	}
+	return n, nil
+}
+
+// setDestinations replaces the destinations of the notifier after a reload.
+func (n *notifier) setDestinations(destinations []*destination) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.destinations = destinations
+}
+
+// wait waits for the notifications that are being sent.
+func (n *notifier) wait() {
+	n.wg.Wait()
+}
+
+// check sends notifications for the releases that were not seen before,
+// including those published since the last check that are no longer the
+// latest.
+func (n *notifier) check(ctx context.Context, releases []release) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	changed := false
+	for _, r := range releases {
+		for _, p := range n.newReleases(r) {
+			n.seen[p.ID] = true
+			changed = true
+
+			if !p.notify {
+				continue
+			}
+
+			e := releaseEvent{
+				Event:       "release",
+				Repository:  r.Repository.GetFullName(),
+				Tag:         p.Tag,
+				Name:        p.Name,
+				URL:         p.URL,
+				PublishedAt: p.PublishedAt,
+			}
+			log := logFor(ctx).WithFields(logrus.Fields{
+				logFieldRepo: e.Repository,
+				logFieldTag:  e.Tag,
+			})
+			log.Info("New release")
+
+			for _, d := range n.destinations {
+				if !d.matches(e.Repository) {
+					continue
+				}
+				n.wg.Add(1)
+				go func(d *destination) {
+					defer n.wg.Done()
+					if err := d.send(n.ctx, e); err != nil {
+						log.Warnf("sending notification to %s failed: %v", d, err)
+					}
+				}(d)
+			}
+		}
+	}
+	n.checked = time.Now()
+
+	if changed {
+		if err := saveState(notifyStateFile, n.seen); err != nil {
+			logFor(ctx).Warnf("saving %s failed: %v", notifyStateFile, err)
+		}
+	}
+}
+
+// newReleases returns the releases of r that were not seen before, oldest
+// first, and if we should notify about each of them. Of a repository we have
+// seen releases of, the releases published after the newest seen one are
+// notified, so older releases we never notified about are not. A repository
+// we have not seen any release of was just added or its releases became
+// visible, for example because --orgs or the config changed, so only the
+// releases published since the last check are notified.
+func (n *notifier) newReleases(r release) []newRelease {
+	var (
+		newest  time.Time
+		seenAny bool
+	)
+	for _, p := range r.Published {
+		if n.seen[p.ID] {
+			seenAny = true
+			if p.PublishedAt.After(newest) {
+				newest = p.PublishedAt
+			}
+		}
+	}
+	if !seenAny {
+		newest = n.checked
+	}
+
+	var result []newRelease
+	for i := len(r.Published) - 1; i >= 0; i-- {
+		p := r.Published[i]
+		if n.seen[p.ID] {
+			continue
+		}
+		result = append(result, newRelease{
+			publishedRelease: p,
+			notify:           !newest.IsZero() && p.PublishedAt.After(newest),
+		})
+	}
+	return result
+}
+
+// newRelease is a release that was not seen before.
+type newRelease struct {
+	publishedRelease
+	notify bool
+}
@@ -0,0 +1,271 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/github"
+)
+
+func testRelease(repo string, published ...publishedRelease) release {
+	owner, name := strings.Split(repo, "/")[0], strings.Split(repo, "/")[1]
+	return release{
+		Repository: &github.Repository{
+			Name:     github.String(name),
+			FullName: github.String(repo),
+			Owner:    &github.User{Login: github.String(owner)},
+		},
+		Published: published,
+	}
+}
+
+func testPublished(id int64, tag string, day int) publishedRelease {
+	return publishedRelease{
+		ID:          id,
+		Tag:         tag,
+		URL:         "https://github.com/releases/" + tag,
+		PublishedAt: time.Date(2026, 1, day, 0, 0, 0, 0, time.UTC),
+	}
+}
+
+func TestNotifierCheck(t *testing.T) {
+	stateDir = t.TempDir()
+
+	var (
+		mu   sync.Mutex
+		tags []string
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		var e releaseEvent
+		if err := json.NewDecoder(req.Body).Decode(&e); err != nil {
+			t.Errorf("decoding the webhook failed: %v", err)
+		}
+		mu.Lock()
+		tags = append(tags, e.Repository+"@"+e.Tag)
+		mu.Unlock()
+	}))
+	defer srv.Close()
+
+	d, err := parseDestination("webhook=" + srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n, err := newNotifier(context.Background(), []*destination{d})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		v1 = testPublished(1, "v1", 1)
+		v2 = testPublished(2, "v2", 2)
+		v3 = testPublished(3, "v3", 3)
+		a1 = testPublished(11, "a1", 1)
+		a2 = testPublished(12, "a2", 2)
+		// b1 is published after the checks.
+		b1 = publishedRelease{ID: 21, Tag: "b1", PublishedAt: time.Now().Add(time.Hour)}
+	)
+	steps := []struct {
+		name     string
+		releases []release
+		want     []string
+	}{
+		{
+			name:     "existing releases are not notified on the first check",
+			releases: []release{testRelease("o/r", v1)},
+		},
+		{
+			name:     "every release since the last check is notified",
+			releases: []release{testRelease("o/r", v3, v2, v1)},
+			want:     []string{"o/r@v2", "o/r@v3"},
+		},
+		{
+			name:     "the existing releases of a new repository are not notified",
+			releases: []release{testRelease("o/r", v3, v2, v1), testRelease("o/a", a2, a1)},
+		},
+		{
+			name:     "the releases of a new repository since the last check are notified",
+			releases: []release{testRelease("o/r", v3, v2, v1), testRelease("o/a", a2, a1), testRelease("o/b", b1)},
+			want:     []string{"o/b@b1"},
+		},
+		{
+			name:     "seen releases are not notified again",
+			releases: []release{testRelease("o/r", v3, v2, v1), testRelease("o/a", a2, a1), testRelease("o/b", b1)},
+		},
+	}
+	for _, step := range steps {
+		mu.Lock()
+		tags = nil
+		mu.Unlock()
+
+		n.check(context.Background(), step.releases)
+		n.wait()
+
+		mu.Lock()
+		sort.Strings(tags)
+		if strings.Join(tags, " ") != strings.Join(step.want, " ") {
+			t.Errorf("%s: got %v, want %v", step.name, tags, step.want)
+		}
+		mu.Unlock()
+	}
+
+	// The seen releases survive a restart.
+	n, err = newNotifier(context.Background(), []*destination{d})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !n.seen[3] || !n.seen[12] || !n.seen[21] {
+		t.Errorf("the seen releases were not saved: seen=%v", n.seen)
+	}
+
+	// After a restart, the repositories with seen releases are still
+	// notified about, the others are new.
+	mu.Lock()
+	tags = nil
+	mu.Unlock()
+	v4 := publishedRelease{ID: 4, Tag: "v4", PublishedAt: time.Now().Add(time.Hour)}
+	c1 := publishedRelease{ID: 31, Tag: "c1", PublishedAt: time.Now().Add(time.Hour)}
+	n.check(context.Background(), []release{testRelease("o/r", v4, v3, v2, v1), testRelease("o/c", c1)})
+	n.wait()
+	mu.Lock()
+	if strings.Join(tags, " ") != "o/r@v4" {
+		t.Errorf("after a restart: got %v, want [o/r@v4]", tags)
+	}
+	mu.Unlock()
+}
+
+func TestSlackNotification(t *testing.T) {
+	var got map[string]string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if err := json.NewDecoder(req.Body).Decode(&got); err != nil {
+			t.Errorf("decoding the message failed: %v", err)
+		}
+	}))
+	defer srv.Close()
+
+	d, err := parseDestination("slack=" + srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := releaseEvent{Repository: "o/r", Tag: "v1<b>&|x", URL: "https://github.com/o/r/releases/tag/v1<b>&|x"}
+	if err := d.sendOnce(context.Background(), e); err != nil {
+		t.Fatal(err)
+	}
+
+	want := "<https://github.com/o/r/releases/tag/v1&lt;b&gt;&amp;%7Cx|o/r v1&lt;b&gt;&amp;|x> was released"
+	if got["text"] != want {
+		t.Errorf("got %q, want %q", got["text"], want)
+	}
+}
+
+// fakeSMTPServer accepts a single mail and sends its data to mails. If
+// hang is set it accepts connections but never responds.
+func fakeSMTPServer(t *testing.T, hang bool, mails chan<- string) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		if hang {
+			// Wait for the client to give up.
+			conn.Read(make([]byte, 1))
+			return
+		}
+
+		r := bufio.NewReader(conn)
+		reply := func(s string) { conn.Write([]byte(s + "\r\n")) }
+		reply("220 localhost ESMTP")
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			switch cmd := strings.ToUpper(strings.TrimSpace(line)); {
+			case strings.HasPrefix(cmd, "EHLO"), strings.HasPrefix(cmd, "HELO"):
+				reply("250 localhost")
+			case strings.HasPrefix(cmd, "DATA"):
+				reply("354 go ahead")
+				var data strings.Builder
+				for {
+					l, err := r.ReadString('\n')
+					if err != nil {
+						return
+					}
+					if l == ".\r\n" {
+						break
+					}
+					data.WriteString(l)
+				}
+				mails <- data.String()
+				reply("250 ok")
+			case strings.HasPrefix(cmd, "QUIT"):
+				reply("221 bye")
+				return
+			default:
+				reply("250 ok")
+			}
+		}
+	}()
+
+	return l.Addr().String()
+}
+
+func TestSendMail(t *testing.T) {
+	mails := make(chan string, 1)
+	addr := fakeSMTPServer(t, false, mails)
+
+	u, err := url.Parse("smtp://" + addr + "?from=releases@example.com&to=a@example.com,b@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := releaseEvent{Repository: "o/r", Tag: "v1", URL: "https://github.com/o/r/releases/v1"}
+	if err := sendMail(context.Background(), u, e); err != nil {
+		t.Fatal(err)
+	}
+
+	mail := <-mails
+	for _, want := range []string{
+		"To: a@example.com, b@example.com\r\n",
+		"Subject: o/r v1 was released\r\n",
+		e.String(),
+	} {
+		if !strings.Contains(mail, want) {
+			t.Errorf("mail does not contain %q:\n%s", want, mail)
+		}
+	}
+}
+
+func TestSendMailHangingServer(t *testing.T) {
+	addr := fakeSMTPServer(t, true, nil)
+
+	u, err := url.Parse("smtp://" + addr + "?from=releases@example.com&to=a@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	if err := sendMail(ctx, u, releaseEvent{Repository: "o/r", Tag: "v1"}); err == nil {
+		t.Fatal("expected an error from a server that does not respond")
+	}
+	if d := time.Since(start); d > 5*time.Second {
+		t.Errorf("sendMail took %s to give up", d)
+	}
+}
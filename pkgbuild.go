@@ -0,0 +1,111 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"text/template"
+)
+
+const (
+	pkgbuildTmpl = `# Maintainer: {{.Maintainer}}
+# Generated by https://github.com/genuinetools/releases, do not edit.
+
+pkgname={{.Name}}-bin
+pkgver={{.Version}}
+pkgrel=1
+pkgdesc={{quote .Description}}
+arch=({{range $i, $s := .Sources}}{{if $i}} {{end}}'{{$s.Arch}}'{{end}})
+url={{quote .Homepage}}
+license=({{quote .License}})
+provides=('{{.Name}}')
+conflicts=('{{.Name}}')
+{{range .Sources}}
+source_{{.Arch}}=({{quote (printf "%s-%s-%s::%s" $.Name $.Version .Arch .URL)}})
+sha256sums_{{.Arch}}=('{{.SHA256}}')
+{{- end}}
+
+package() {
+	install -Dm755 "${srcdir}/{{.Name}}-${pkgver}-${CARCH}" "${pkgdir}/usr/bin/{{.Name}}"
+}
+`
+)
+
+// pkgbuildArchitectures maps GOARCH to the architecture names used by
+// Arch Linux (and Arch Linux ARM).
+var pkgbuildArchitectures = map[string]string{
+	"amd64": "x86_64",
+	"arm64": "aarch64",
+	"arm":   "armv7h",
+	"386":   "i686",
+}
+
+var pkgbuildTemplate = template.Must(template.New("").Funcs(template.FuncMap{
+	"quote": shellQuote,
+}).Parse(pkgbuildTmpl))
+
+// pkgbuild holds the data for rendering an AUR -bin PKGBUILD.
+type pkgbuild struct {
+	Maintainer  string
+	Name        string
+	Version     string
+	Description string
+	Homepage    string
+	License     string
+	Sources     []pkgbuildSource
+}
+
+type pkgbuildSource struct {
+	Arch   string
+	URL    string
+	SHA256 string
+}
+
+// pkgbuildBytes returns a PKGBUILD for the linux binaries of the latest
+// release.
+func pkgbuildBytes(r release) ([]byte, error) {
+	if r.Release == nil {
+		return nil, fmt.Errorf("%s has no release", r.Repository.GetFullName())
+	}
+
+	p := pkgbuild{
+		Maintainer: r.Repository.GetOwner().GetLogin(),
+		Name:       r.Repository.GetName(),
+		// pkgver may not contain hyphens.
+		Version:     strings.Replace(strings.TrimPrefix(r.Release.GetTagName(), "v"), "-", "_", -1),
+		Description: r.Repository.GetDescription(),
+		Homepage:    r.Repository.GetHTMLURL(),
+		License:     r.Repository.GetLicense().GetSPDXID(),
+	}
+	if p.License == "" {
+		p.License = "custom"
+	}
+
+	for _, arch := range []string{"amd64", "arm64", "arm", "386"} {
+		b, ok := r.Platforms["linux"][arch]
+		if !ok || b.BinaryURL == "" || b.BinarySHA256 == "" {
+			continue
+		}
+
+		p.Sources = append(p.Sources, pkgbuildSource{
+			Arch:   pkgbuildArchitectures[arch],
+			URL:    b.BinaryURL,
+			SHA256: b.BinarySHA256,
+		})
+	}
+
+	if len(p.Sources) < 1 {
+		return nil, fmt.Errorf("%s %s has no linux binaries with checksums", r.Repository.GetFullName(), r.Release.GetTagName())
+	}
+
+	var b bytes.Buffer
+	if err := pkgbuildTemplate.Execute(&b, p); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// shellQuote quotes s for use as a single word in a shell script.
+func shellQuote(s string) string {
+	return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestPKGBUILD(t *testing.T) {
+	b, err := pkgbuildBytes(testManifestRelease("genuinetools/img", "v0.5.11-rc.1", "linux/amd64", "linux/arm64!", "linux/arm", "windows/amd64"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkGolden(t, "PKGBUILD", b)
+
+	if _, err := pkgbuildBytes(testManifestRelease("genuinetools/img", "v0.5.11", "linux/amd64!", "darwin/amd64")); err == nil {
+		t.Error("expected an error for a release without linux checksums")
+	}
+}
+
+func TestShellQuote(t *testing.T) {
+	testCases := []struct {
+		s, want string
+	}{
+		{"", `''`},
+		{"img", `'img'`},
+		{"it's $HOME", `'it'\''s $HOME'`},
+	}
+
+	for _, tc := range testCases {
+		if got := shellQuote(tc.s); got != tc.want {
+			t.Errorf("%q: got %s, want %s", tc.s, got, tc.want)
+		}
+	}
+}
@@ -1,9 +1,32 @@
 package main
 
+import (
+	"fmt"
+	"strings"
+)
+
 const (
-	releaseTmpl = `Below are easy install instructions by OS and Architecture. As always there are always the standard instructions in the [README.md](README.md) as well.
+	// releaseBodyStart and releaseBodyEnd mark the generated section of a
+	// release body. Everything outside of them is left untouched.
+	releaseBodyStart = "<!-- releases:start -->"
+	releaseBodyEnd   = "<!-- releases:end -->"
+
+	// releaseBodyLegacyStart starts the install instructions of bodies that
+	// were generated before the markers existed.
+	releaseBodyLegacyStart = "Below are easy install instructions by OS and Architecture."
+
+	releaseTmpl = `<< with .Changelog >>### Changelog
+<< range .Groups >>
+#### << .Title >>
+
+<< range .Entries >>- << if .Breaking >>**BREAKING** << end >><< .Title >><< if .PullRequest >> (#<< .PullRequest >>)<< else >> (<< .SHA >>)<< end >><< with .Author >> @<< . >><< end >>
+<< end >>
+<<- end >>
+**Full Changelog**: << .CompareURL >>
 
-<< range $os, $v := . >>
+<< end >>Below are easy install instructions by OS and Architecture. As always there are always the standard instructions in the [README.md](README.md) as well.
+
+<< range $os, $v := .Platforms >>
 #### << $os  >>
 
 << range $arch, $r := $v >>
@@ -27,3 +50,32 @@ $ << $r.Repository.Name >> -h
 <<end>>
 `
 )
+
+// mergeReleaseBody replaces the generated section of body with generated.
+// If body has no generated section yet, it is appended after the existing
+// release notes. A marker without its counterpart is an error, since we
+// cannot tell where the generated section ends.
+func mergeReleaseBody(body, generated string) (string, error) {
+	section := releaseBodyStart + "\n" + strings.TrimSpace(generated) + "\n" + releaseBodyEnd
+
+	start := strings.Index(body, releaseBodyStart)
+	end := strings.Index(body, releaseBodyEnd)
+	switch {
+	case start >= 0 && end > start:
+		return body[:start] + section + body[end+len(releaseBodyEnd):], nil
+	case start >= 0:
+		return "", fmt.Errorf("the body has %s without %s after it", releaseBodyStart, releaseBodyEnd)
+	case end >= 0:
+		return "", fmt.Errorf("the body has %s without %s before it", releaseBodyEnd, releaseBodyStart)
+	}
+
+	// Bodies written before the markers existed end with the generated
+	// install instructions, everything before them is release notes.
+	if i := strings.Index(body, releaseBodyLegacyStart); i >= 0 {
+		body = body[:i]
+	}
+	if strings.TrimSpace(body) == "" {
+		return section + "\n", nil
+	}
+	return strings.TrimRight(body, "\r\n\t ") + "\n\n" + section + "\n", nil
+}
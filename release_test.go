@@ -0,0 +1,92 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMergeReleaseBody(t *testing.T) {
+	const generated = "install it\n"
+	section := releaseBodyStart + "\ninstall it\n" + releaseBodyEnd
+
+	testCases := []struct {
+		name string
+		body string
+		want string
+		err  string
+	}{
+		{
+			name: "empty body",
+			body: "",
+			want: section + "\n",
+		},
+		{
+			name: "whitespace only",
+			body: " \r\n\n",
+			want: section + "\n",
+		},
+		{
+			name: "release notes",
+			body: "Fixes a bug.\r\n\n",
+			want: "Fixes a bug.\n\n" + section + "\n",
+		},
+		{
+			name: "existing section",
+			body: "Fixes a bug.\n\n" + releaseBodyStart + "\nold\n" + releaseBodyEnd + "\n\nThanks!\n",
+			want: "Fixes a bug.\n\n" + section + "\n\nThanks!\n",
+		},
+		{
+			name: "existing section only",
+			body: releaseBodyStart + "\nold\n" + releaseBodyEnd,
+			want: section,
+		},
+		{
+			name: "legacy instructions",
+			body: "Fixes a bug.\n\n" + releaseBodyLegacyStart + "\n\n#### Linux\nold\n",
+			want: "Fixes a bug.\n\n" + section + "\n",
+		},
+		{
+			name: "legacy instructions only",
+			body: releaseBodyLegacyStart + "\nold\n",
+			want: section + "\n",
+		},
+		{
+			name: "start without end",
+			body: "Fixes a bug.\n" + releaseBodyStart + "\nold\n",
+			err:  "without " + releaseBodyEnd + " after it",
+		},
+		{
+			name: "end without start",
+			body: "Fixes a bug.\nold\n" + releaseBodyEnd,
+			err:  "without " + releaseBodyStart + " before it",
+		},
+		{
+			name: "end before start",
+			body: releaseBodyEnd + "\nold\n" + releaseBodyStart,
+			err:  "without " + releaseBodyEnd + " after it",
+		},
+	}
+
+	for _, tc := range testCases {
+		got, err := mergeReleaseBody(tc.body, generated)
+		if tc.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tc.err) {
+				t.Errorf("%s: got error %v, want it to contain %q", tc.name, err, tc.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tc.name, err)
+			continue
+		}
+		if got != tc.want {
+			t.Errorf("%s: got %q, want %q", tc.name, got, tc.want)
+		}
+
+		// Merging again must not change the body.
+		again, err := mergeReleaseBody(got, generated)
+		if err != nil || again != got {
+			t.Errorf("%s: merging again got %q (%v), want %q", tc.name, again, err, got)
+		}
+	}
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/google/go-github/github"
+	"github.com/sirupsen/logrus"
+)
+
+// configMu guards the globals a reload changes against the HTTP handlers
+// and release body updates reading them at the same time.
+var configMu sync.RWMutex
+
+// savedConfig holds the globals set by configure, so a reload that fails
+// can be rolled back.
+type savedConfig struct {
+	port              int
+	interval          time.Duration
+	enturl            string
+	orgs              stringSlice
+	nouser            bool
+	appID             int64
+	appKeyFile        string
+	appInstallationID int64
+	anonymous         bool
+	updateReleaseBody updateMode
+	updateLatest      int
+	updateSince       string
+	updateSinceTime   time.Time
+	updateWorkers     int
+	stateDir          string
+	cacheBackend      string
+	cacheDir          string
+	cacheMaxSize      byteSize
+	readyIntervals    int
+	downloadTimeout   time.Duration
+	platforms         []string
+	staleAfter        time.Duration
+	notify            stringSlice
+	destinations      []*destination
+	manifestDir       string
+	changelogMode     string
+	templateDir       string
+	tmpls             *templates
+	include           []string
+	exclude           []string
+	binaryPattern     string
+	repoOverrides     []repoOverride
+	downloadClient    *http.Client
+	currentUser       string
+}
+
+func currentConfig() savedConfig {
+	return savedConfig{
+		port:              port,
+		interval:          interval,
+		enturl:            enturl,
+		orgs:              orgs,
+		nouser:            nouser,
+		appID:             appID,
+		appKeyFile:        appKeyFile,
+		appInstallationID: appInstallationID,
+		anonymous:         anonymous,
+		updateReleaseBody: updateReleaseBody,
+		updateLatest:      updateLatest,
+		updateSince:       updateSince,
+		updateSinceTime:   updateSinceTime,
+		updateWorkers:     updateWorkers,
+		stateDir:          stateDir,
+		cacheBackend:      cacheBackend,
+		cacheDir:          cacheDir,
+		cacheMaxSize:      cacheMaxSize,
+		readyIntervals:    readyIntervals,
+		downloadTimeout:   downloadTimeout,
+		platforms:         platforms,
+		staleAfter:        staleAfter,
+		notify:            notify,
+		destinations:      destinations,
+		manifestDir:       manifestDir,
+		changelogMode:     changelogMode,
+		templateDir:       templateDir,
+		tmpls:             tmpls,
+		include:           include,
+		exclude:           exclude,
+		binaryPattern:     binaryPattern,
+		repoOverrides:     repoOverrides,
+		downloadClient:    downloadClient,
+		currentUser:       currentUser,
+	}
+}
+
+func (c savedConfig) restore() {
+	port = c.port
+	interval = c.interval
+	enturl = c.enturl
+	orgs = c.orgs
+	nouser = c.nouser
+	appID = c.appID
+	appKeyFile = c.appKeyFile
+	appInstallationID = c.appInstallationID
+	anonymous = c.anonymous
+	updateReleaseBody = c.updateReleaseBody
+	updateLatest = c.updateLatest
+	updateSince = c.updateSince
+	updateSinceTime = c.updateSinceTime
+	updateWorkers = c.updateWorkers
+	stateDir = c.stateDir
+	cacheBackend = c.cacheBackend
+	cacheDir = c.cacheDir
+	cacheMaxSize = c.cacheMaxSize
+	readyIntervals = c.readyIntervals
+	downloadTimeout = c.downloadTimeout
+	platforms = c.platforms
+	staleAfter = c.staleAfter
+	notify = c.notify
+	destinations = c.destinations
+	manifestDir = c.manifestDir
+	changelogMode = c.changelogMode
+	templateDir = c.templateDir
+	tmpls = c.tmpls
+	include = c.include
+	exclude = c.exclude
+	binaryPattern = c.binaryPattern
+	repoOverrides = c.repoOverrides
+	downloadClient = c.downloadClient
+	currentUser = c.currentUser
+}
+
+// reload re-reads the config file and templates and creates a new client
+// with them. If anything is invalid or the client cannot be created the
+// previous configuration is kept. Settings that are only read at startup
+// keep their previous value until a restart.
+func reload(ctx context.Context, fs *flag.FlagSet) (*github.Client, string, error) {
+	next, err := loadReload(ctx, fs)
+	if err != nil {
+		return nil, "", err
+	}
+
+	// Rebuild the client, since the url, orgs and user might have changed.
+	// It makes requests to GitHub, so the handlers keep reading the
+	// previous config until it works.
+	client, affiliation, err := next.newClient(ctx)
+	if err != nil {
+		return nil, "", err
+	}
+
+	configMu.Lock()
+	next.restore()
+	configMu.Unlock()
+	return client, affiliation, nil
+}
+
+// loadReload applies the flags and config file like at startup and returns
+// the result, leaving the globals as they were.
+func loadReload(ctx context.Context, fs *flag.FlagSet) (savedConfig, error) {
+	configMu.Lock()
+	defer configMu.Unlock()
+
+	prev := currentConfig()
+	defer prev.restore()
+	if err := configure(fs); err != nil {
+		return savedConfig{}, err
+	}
+
+	// restart logs that changing the flag requires a restart.
+	restart := func(name string, from, to interface{}) {
+		logFor(ctx).WithFields(logrus.Fields{
+			"flag": name,
+			"from": from,
+			"to":   to,
+		}).Warnf("Changing --%s requires a restart.", name)
+	}
+	if port != prev.port {
+		restart("port", prev.port, port)
+		port = prev.port
+	}
+	if stateDir != prev.stateDir {
+		restart("state-dir", prev.stateDir, stateDir)
+		stateDir = prev.stateDir
+	}
+	if cacheBackend != prev.cacheBackend || cacheDir != prev.cacheDir || cacheMaxSize != prev.cacheMaxSize {
+		logFor(ctx).Warn("Changing the HTTP cache requires a restart.")
+		cacheBackend, cacheDir, cacheMaxSize = prev.cacheBackend, prev.cacheDir, prev.cacheMaxSize
+	}
+	if updateReleaseBody != prev.updateReleaseBody {
+		restart("update-release-body", prev.updateReleaseBody.String(), updateReleaseBody.String())
+		updateReleaseBody = prev.updateReleaseBody
+	}
+	if updateWorkers != prev.updateWorkers {
+		restart("update-workers", prev.updateWorkers, updateWorkers)
+		updateWorkers = prev.updateWorkers
+	}
+
+	return currentConfig(), nil
+}
+
+// currentTemplates returns the templates, which a reload may replace.
+func currentTemplates() *templates {
+	configMu.RLock()
+	defer configMu.RUnlock()
+	return tmpls
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// scoopArchitectures maps GOARCH to the architecture names used by Scoop.
+var scoopArchitectures = map[string]string{
+	"amd64": "64bit",
+	"386":   "32bit",
+	"arm64": "arm64",
+}
+
+// scoopManifest is the app manifest format for Scoop buckets.
+// See: https://github.com/ScoopInstaller/Scoop/wiki/App-Manifests
+type scoopManifest struct {
+	Version      string                       `json:"version"`
+	Description  string                       `json:"description,omitempty"`
+	Homepage     string                       `json:"homepage"`
+	License      string                       `json:"license,omitempty"`
+	Architecture map[string]scoopArchitecture `json:"architecture"`
+	Bin          string                       `json:"bin"`
+	Checkver     scoopCheckver                `json:"checkver"`
+	Autoupdate   scoopAutoupdate              `json:"autoupdate"`
+}
+
+type scoopArchitecture struct {
+	URL  string `json:"url"`
+	Hash string `json:"hash,omitempty"`
+}
+
+type scoopCheckver struct {
+	Github string `json:"github"`
+}
+
+type scoopAutoupdate struct {
+	Architecture map[string]scoopAutoupdateArchitecture `json:"architecture"`
+}
+
+type scoopAutoupdateArchitecture struct {
+	URL  string             `json:"url"`
+	Hash scoopAutoupdateURL `json:"hash"`
+}
+
+type scoopAutoupdateURL struct {
+	URL string `json:"url"`
+}
+
+// newScoopManifest creates a Scoop manifest from the windows binaries of
+// the latest release.
+func newScoopManifest(r release) (*scoopManifest, error) {
+	if r.Release == nil {
+		return nil, fmt.Errorf("%s has no release", r.Repository.GetFullName())
+	}
+
+	name := r.Repository.GetName()
+	tag := r.Release.GetTagName()
+	version := strings.TrimPrefix(tag, "v")
+
+	m := &scoopManifest{
+		Version:      version,
+		Description:  r.Repository.GetDescription(),
+		Homepage:     r.Repository.GetHTMLURL(),
+		License:      r.Repository.GetLicense().GetSPDXID(),
+		Architecture: map[string]scoopArchitecture{},
+		// Scoop only creates shims for executables, so each url is
+		// suffixed with a fragment renaming the download to name.exe.
+		Bin: name + ".exe",
+		Checkver: scoopCheckver{
+			Github: r.Repository.GetHTMLURL(),
+		},
+		Autoupdate: scoopAutoupdate{
+			Architecture: map[string]scoopAutoupdateArchitecture{},
+		},
+	}
+
+	for arch, b := range r.Platforms["windows"] {
+		sarch, ok := scoopArchitectures[arch]
+		if !ok || b.BinaryURL == "" {
+			continue
+		}
+
+		m.Architecture[sarch] = scoopArchitecture{
+			URL:  b.BinaryURL + "#/" + m.Bin,
+			Hash: b.BinarySHA256,
+		}
+
+		// Replace the version in the download url so Scoop can find new
+		// releases on its own.
+		u := strings.Replace(b.BinaryURL, "/download/"+tag+"/", "/download/"+strings.Replace(tag, version, "$version", 1)+"/", 1)
+		m.Autoupdate.Architecture[sarch] = scoopAutoupdateArchitecture{
+			URL: u + "#/" + m.Bin,
+			Hash: scoopAutoupdateURL{
+				URL: "$url.sha256",
+			},
+		}
+	}
+
+	if len(m.Architecture) < 1 {
+		return nil, fmt.Errorf("%s %s has no windows binaries", r.Repository.GetFullName(), tag)
+	}
+
+	return m, nil
+}
+
+func scoopManifestBytes(r release) ([]byte, error) {
+	m, err := newScoopManifest(r)
+	if err != nil {
+		return nil, err
+	}
+
+	b, err := json.MarshalIndent(m, "", "    ")
+	if err != nil {
+		return nil, err
+	}
+	return append(b, '\n'), nil
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	units "github.com/docker/go-units"
+	"github.com/google/go-github/github"
+)
+
+const showHelp = `Show the latest release of a repository.`
+
+func (cmd *showCommand) Name() string      { return "show" }
+func (cmd *showCommand) Args() string      { return "<owner/repo>" }
+func (cmd *showCommand) ShortHelp() string { return showHelp }
+func (cmd *showCommand) LongHelp() string  { return showHelp }
+func (cmd *showCommand) Hidden() bool      { return false }
+
+func (cmd *showCommand) Register(fs *flag.FlagSet) {
+	fs.BoolVar(&cmd.json, "json", false, "print the release as JSON")
+}
+
+type showCommand struct {
+	json bool
+}
+
+func (cmd *showCommand) Run(ctx context.Context, args []string) error {
+	if len(args) != 1 {
+		return errors.New("must pass a repository as owner/repo")
+	}
+
+	client, _, err := newClient(ctx)
+	if err != nil {
+		return err
+	}
+
+	r, err := getRelease(ctx, client, args[0])
+	if err != nil {
+		return err
+	}
+
+	if cmd.json {
+		b, err := releaseJSON(r)
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(b))
+		return nil
+	}
+
+	fmt.Printf("%s: %s\n", r.Repository.GetFullName(), r.Repository.GetDescription())
+	fmt.Printf("Release: %s (%s)\n", r.Release.GetTagName(), r.Release.GetHTMLURL())
+	fmt.Printf("Released: %s ago\n", units.HumanDuration(time.Since(r.Release.GetPublishedAt().Time)))
+	fmt.Printf("Downloads: %d\n", r.BinaryDownloadCount)
+	for _, warning := range r.Warnings {
+		fmt.Printf("Warning: %s\n", warning.Message)
+	}
+	fmt.Println()
+
+	var keys []string
+	for osn, v := range r.Platforms {
+		for arch := range v {
+			keys = append(keys, osn+"/"+arch)
+		}
+	}
+	sort.Strings(keys)
+
+	w := tabwriter.NewWriter(os.Stdout, 20, 1, 3, ' ', 0)
+	fmt.Fprintln(w, "OS\tARCH\tURL\tSHA256")
+	for _, k := range keys {
+		s := strings.SplitN(k, "/", 2)
+		b := r.Platforms[s[0]][s[1]]
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", s[0], s[1], b.BinaryURL, b.BinarySHA256)
+	}
+	return w.Flush()
+}
+
+// getRelease fetches the latest release of the repository named like
+// owner/repo, with only the assets and checksums of that release.
+func getRelease(ctx context.Context, client *github.Client, name string) (release, error) {
+	s := strings.SplitN(name, "/", 2)
+	if len(s) != 2 || s[0] == "" || s[1] == "" {
+		return release{}, fmt.Errorf("repository %q must be of the form owner/repo", name)
+	}
+
+	repo, _, err := client.Repositories.Get(ctx, s[0], s[1])
+	if err != nil {
+		return release{}, fmt.Errorf("getting repository %s failed: %v", name, err)
+	}
+
+	r, resp, err := client.Repositories.GetLatestRelease(ctx, s[0], s[1])
+	if resp != nil && resp.StatusCode == http.StatusNotFound {
+		return release{}, fmt.Errorf("%s has no releases", name)
+	}
+	if err != nil {
+		return release{}, fmt.Errorf("getting the latest release of %s failed: %v", name, err)
+	}
+
+	rl := release{
+		Repository: repo,
+		Release:    r,
+	}
+	settings := settingsFor(repo.GetFullName())
+	m := newBinaryMatcher(settings.binary, repo.GetName())
+	if rl.Platforms, err = addReleaseAssets(ctx, client, &rl, r, m, true); err != nil {
+		return release{}, err
+	}
+	rl.Warnings = lintRelease(rl, settings)
+
+	return rl, nil
+}
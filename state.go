@@ -0,0 +1,78 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// loadState decodes the JSON state file name in the state directory into v.
+// It is not an error if the file does not exist yet.
+func loadState(name string, v interface{}) error {
+	b, err := ioutil.ReadFile(stateFile(name))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
+
+// saveState encodes v as JSON to the state file name in the state
+// directory.
+func saveState(name string, v interface{}) error {
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFile(stateFile(name), b)
+}
+
+// stateFile returns the path of the state file name.
+func stateFile(name string) string {
+	configMu.RLock()
+	defer configMu.RUnlock()
+	return filepath.Join(stateDir, name)
+}
+
+// snapshotStateFile holds the releases of the last refresh, saved when
+// shutting down.
+const snapshotStateFile = "snapshot.json"
+
+// savedSnapshot is the content of the snapshot state file.
+type savedSnapshot struct {
+	Updated  time.Time `json:"updated"`
+	Releases []release `json:"releases"`
+}
+
+// save persists the releases of the snapshot to the state directory.
+func (s *snapshot) save() error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.releases == nil {
+		return nil
+	}
+	return saveState(snapshotStateFile, savedSnapshot{Updated: s.updated, Releases: s.releases})
+}
+
+// load restores the releases saved by save and renders the index for them.
+func (s *snapshot) load() error {
+	var saved savedSnapshot
+	if err := loadState(snapshotStateFile, &saved); err != nil {
+		return err
+	}
+	if saved.Releases == nil {
+		return nil
+	}
+
+	var b bytes.Buffer
+	if err := currentTemplates().index.Execute(&b, saved.Releases); err != nil {
+		return err
+	}
+	s.set(saved.Releases, b, saved.Updated)
+	return nil
+}
@@ -0,0 +1,324 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	htmltemplate "html/template"
+	"net/http"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	statsDateFormat = "2006-01-02"
+
+	// statsDays and statsWeeks are how far back the charts go.
+	statsDays  = 30
+	statsWeeks = 12
+)
+
+// downloadSeries holds the download counts of the assets of a repository
+// per day. Only the last count of each day is kept.
+type downloadSeries struct {
+	// Assets maps "{tag}/{asset}" to the download count by date.
+	Assets map[string]map[string]int `json:"assets"`
+}
+
+// statPoint is a single point in a download count time series.
+type statPoint struct {
+	Date  string `json:"date"`
+	Count int    `json:"count"`
+	// Delta is the number of downloads since the previous point.
+	Delta int `json:"delta"`
+}
+
+// repoStats is the download count time series of a repository.
+type repoStats struct {
+	Repository string                 `json:"repository"`
+	Total      []statPoint            `json:"total"`
+	Weekly     []statPoint            `json:"weekly"`
+	Releases   map[string][]statPoint `json:"releases"`
+	Assets     map[string][]statPoint `json:"assets"`
+}
+
+// statsStore persists the download count time series of every repository
+// in the state directory.
+type statsStore struct {
+	mu     sync.Mutex
+	series map[string]*downloadSeries
+}
+
+var downloadStats = &statsStore{
+	series: map[string]*downloadSeries{},
+}
+
+// get returns the series for the repository, loading it from disk if we
+// have not seen it yet.
+func (s *statsStore) get(repo string) (*downloadSeries, error) {
+	if ds, ok := s.series[repo]; ok {
+		return ds, nil
+	}
+
+	ds := &downloadSeries{}
+	if err := loadState(statsFile(repo), ds); err != nil {
+		return nil, err
+	}
+	if ds.Assets == nil {
+		ds.Assets = map[string]map[string]int{}
+	}
+	s.series[repo] = ds
+	return ds, nil
+}
+
+// record adds the current download counts of every asset.
+func (s *statsStore) record(releases []release, now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	date := now.UTC().Format(statsDateFormat)
+	for _, r := range releases {
+		repo := r.Repository.GetFullName()
+		ds, err := s.get(repo)
+		if err != nil {
+			logrus.WithField(logFieldRepo, repo).Warnf("loading download stats failed: %v", err)
+			continue
+		}
+
+		for _, a := range r.Assets {
+			key := a.Tag + "/" + a.Name
+			if ds.Assets[key] == nil {
+				ds.Assets[key] = map[string]int{}
+			}
+			ds.Assets[key][date] = a.DownloadCount
+		}
+
+		if err := saveState(statsFile(repo), ds); err != nil {
+			logrus.WithField(logFieldRepo, repo).Warnf("saving download stats failed: %v", err)
+		}
+	}
+}
+
+// stats returns the daily time series of the repository for the last
+// days, with totals by release and for the whole repository.
+func (s *statsStore) stats(repo string, now time.Time) (*repoStats, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ds, err := s.get(repo)
+	if err != nil {
+		return nil, err
+	}
+
+	dates := make([]string, statsWeeks*7)
+	for i := range dates {
+		dates[i] = now.UTC().AddDate(0, 0, i-len(dates)+1).Format(statsDateFormat)
+	}
+
+	rs := &repoStats{
+		Repository: repo,
+		Releases:   map[string][]statPoint{},
+		Assets:     map[string][]statPoint{},
+	}
+	total := unknownSeries(len(dates))
+	releases := map[string][]int{}
+	for key, counts := range ds.Assets {
+		series := fillSeries(counts, dates)
+		rs.Assets[key] = toPoints(dates, series)
+
+		tag := key[:strings.LastIndex(key, "/")]
+		if releases[tag] == nil {
+			releases[tag] = unknownSeries(len(dates))
+		}
+		addSeries(releases[tag], series)
+		addSeries(total, series)
+	}
+	for tag, series := range releases {
+		rs.Releases[tag] = toPoints(dates, series)
+	}
+	rs.Total = toPoints(dates, total)
+
+	// Sum the daily deltas into weeks ending today.
+	for i := 6; i < len(dates); i += 7 {
+		p := statPoint{Date: dates[i], Count: rs.Total[i].Count}
+		for _, d := range rs.Total[i-6 : i+1] {
+			p.Delta += d.Delta
+		}
+		rs.Weekly = append(rs.Weekly, p)
+	}
+
+	return rs, nil
+}
+
+// fillSeries returns the counts for dates, carrying the last known count
+// forward over the days we have no data for. Days before the first count
+// are -1.
+func fillSeries(counts map[string]int, dates []string) []int {
+	// Find the last count before the first date.
+	var known []string
+	for d := range counts {
+		known = append(known, d)
+	}
+	sort.Strings(known)
+
+	last := -1
+	for _, d := range known {
+		if d >= dates[0] {
+			break
+		}
+		last = counts[d]
+	}
+
+	series := make([]int, len(dates))
+	for i, d := range dates {
+		if c, ok := counts[d]; ok {
+			last = c
+		}
+		series[i] = last
+	}
+	return series
+}
+
+func unknownSeries(n int) []int {
+	series := make([]int, n)
+	for i := range series {
+		series[i] = -1
+	}
+	return series
+}
+
+// addSeries adds b to a, a day stays unknown only if it is unknown in
+// both.
+func addSeries(a, b []int) {
+	for i := range a {
+		if b[i] < 0 {
+			continue
+		}
+		if a[i] < 0 {
+			a[i] = 0
+		}
+		a[i] += b[i]
+	}
+}
+
+// toPoints converts the counts to points. There is no delta for the first
+// day with data since we do not know when those downloads happened.
+func toPoints(dates []string, counts []int) []statPoint {
+	points := make([]statPoint, len(dates))
+	for i := range dates {
+		points[i].Date = dates[i]
+		if counts[i] < 0 {
+			continue
+		}
+		points[i].Count = counts[i]
+		if i > 0 && counts[i-1] >= 0 && counts[i] >= counts[i-1] {
+			points[i].Delta = counts[i] - counts[i-1]
+		}
+	}
+	return points
+}
+
+func statsFile(repo string) string {
+	return filepath.Join("stats", repo+".json")
+}
+
+// statsHandler serves the download stats page of a repository at
+// /stats/{owner}/{repo} and the time series at /api/v1/stats/{owner}/{repo}.
+func (s *snapshot) statsHandler(prefix string, api bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		parts := strings.Split(strings.Trim(strings.TrimPrefix(req.URL.Path, prefix), "/"), "/")
+		if len(parts) != 2 {
+			http.NotFound(w, req)
+			return
+		}
+
+		r, ok := s.find(parts[0], parts[1])
+		if !ok {
+			http.NotFound(w, req)
+			return
+		}
+
+		rs, err := downloadStats.stats(r.Repository.GetFullName(), time.Now())
+		if err != nil {
+			logrus.WithField(logFieldRepo, r.Repository.GetFullName()).Warnf("getting download stats failed: %v", err)
+			http.Error(w, "getting download stats failed", http.StatusInternalServerError)
+			return
+		}
+
+		if api {
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(rs)
+			return
+		}
+
+		b, err := renderStats(r, rs)
+		if err != nil {
+			logrus.WithField(logFieldRepo, r.Repository.GetFullName()).Warnf("executing stats template failed: %v", err)
+			http.Error(w, "executing template failed", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/html")
+		w.Write(b)
+	}
+}
+
+// renderStats renders the download stats page of a repository.
+func renderStats(r release, rs *repoStats) ([]byte, error) {
+	var b bytes.Buffer
+	if err := statsTemplate.Execute(&b, struct {
+		Release release
+		Daily   htmltemplate.HTML
+		Weekly  htmltemplate.HTML
+	}{
+		Release: r,
+		Daily:   barChart(rs.Total[len(rs.Total)-statsDays:]),
+		Weekly:  barChart(rs.Weekly),
+	}); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+var statsTemplate = htmltemplate.Must(htmltemplate.New("").Parse(statsTmpl))
+
+// barChart renders the deltas of points as an SVG bar chart.
+func barChart(points []statPoint) htmltemplate.HTML {
+	const (
+		width   = 720
+		height  = 200
+		padding = 20
+	)
+
+	max := 1
+	for _, p := range points {
+		if p.Delta > max {
+			max = p.Delta
+		}
+	}
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d">`, width, height+2*padding, width, height+2*padding)
+	fmt.Fprintf(&b, `<text x="0" y="12" font-size="12">%d</text>`, max)
+	fmt.Fprintf(&b, `<line x1="0" y1="%d" x2="%d" y2="%d" stroke="#dee2e6"/>`, height+padding, width, height+padding)
+
+	bw := float64(width) / float64(len(points))
+	for i, p := range points {
+		h := float64(p.Delta) / float64(max) * height
+		x := float64(i) * bw
+		fmt.Fprintf(&b, `<rect x="%.1f" y="%.1f" width="%.1f" height="%.1f" fill="#0366d6"><title>%s: %d</title></rect>`,
+			x+1, float64(height+padding)-h, bw-2, h, htmltemplate.HTMLEscapeString(p.Date), p.Delta)
+	}
+	if len(points) > 0 {
+		fmt.Fprintf(&b, `<text x="0" y="%d" font-size="12">%s</text>`, height+2*padding-4, points[0].Date)
+		fmt.Fprintf(&b, `<text x="%d" y="%d" font-size="12" text-anchor="end">%s</text>`, width, height+2*padding-4, points[len(points)-1].Date)
+	}
+	b.WriteString(`</svg>`)
+
+	return htmltemplate.HTML(b.String())
+}
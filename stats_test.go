@@ -0,0 +1,127 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFillSeries(t *testing.T) {
+	dates := []string{"2020-01-03", "2020-01-04", "2020-01-05", "2020-01-06"}
+
+	testCases := []struct {
+		name   string
+		counts map[string]int
+		want   []int
+	}{
+		{
+			name:   "no data",
+			counts: map[string]int{},
+			want:   []int{-1, -1, -1, -1},
+		},
+		{
+			name:   "every day",
+			counts: map[string]int{"2020-01-03": 1, "2020-01-04": 2, "2020-01-05": 3, "2020-01-06": 4},
+			want:   []int{1, 2, 3, 4},
+		},
+		{
+			name:   "unknown before the first count",
+			counts: map[string]int{"2020-01-05": 7},
+			want:   []int{-1, -1, 7, 7},
+		},
+		{
+			name:   "carried forward over gaps",
+			counts: map[string]int{"2020-01-03": 5, "2020-01-05": 9},
+			want:   []int{5, 5, 9, 9},
+		},
+		{
+			name:   "carried from before the first date",
+			counts: map[string]int{"2019-12-01": 1, "2020-01-01": 3, "2020-01-04": 4},
+			want:   []int{3, 4, 4, 4},
+		},
+		{
+			name:   "after the last date",
+			counts: map[string]int{"2020-02-01": 3},
+			want:   []int{-1, -1, -1, -1},
+		},
+	}
+
+	for _, tc := range testCases {
+		if got := fillSeries(tc.counts, dates); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s: got %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestAddSeries(t *testing.T) {
+	testCases := []struct {
+		name string
+		a, b []int
+		want []int
+	}{
+		{"both known", []int{1, 2}, []int{3, 4}, []int{4, 6}},
+		{"both unknown", []int{-1, -1}, []int{-1, -1}, []int{-1, -1}},
+		{"a unknown", []int{-1, 2}, []int{3, 4}, []int{3, 6}},
+		{"b unknown", []int{1, 2}, []int{-1, 4}, []int{1, 6}},
+		{"into unknown", unknownSeries(3), []int{-1, 0, 5}, []int{-1, 0, 5}},
+	}
+
+	for _, tc := range testCases {
+		addSeries(tc.a, tc.b)
+		if !reflect.DeepEqual(tc.a, tc.want) {
+			t.Errorf("%s: got %v, want %v", tc.name, tc.a, tc.want)
+		}
+	}
+}
+
+func TestToPoints(t *testing.T) {
+	dates := []string{"2020-01-01", "2020-01-02", "2020-01-03", "2020-01-04", "2020-01-05"}
+
+	testCases := []struct {
+		name   string
+		counts []int
+		want   []statPoint
+	}{
+		{
+			name:   "deltas",
+			counts: []int{10, 12, 12, 20, 21},
+			want: []statPoint{
+				{Date: "2020-01-01", Count: 10},
+				{Date: "2020-01-02", Count: 12, Delta: 2},
+				{Date: "2020-01-03", Count: 12},
+				{Date: "2020-01-04", Count: 20, Delta: 8},
+				{Date: "2020-01-05", Count: 21, Delta: 1},
+			},
+		},
+		{
+			// There is no delta for the first day with data.
+			name:   "unknown days",
+			counts: []int{-1, -1, 5, 6, 6},
+			want: []statPoint{
+				{Date: "2020-01-01"},
+				{Date: "2020-01-02"},
+				{Date: "2020-01-03", Count: 5},
+				{Date: "2020-01-04", Count: 6, Delta: 1},
+				{Date: "2020-01-05", Count: 6},
+			},
+		},
+		{
+			// Counts drop when assets are deleted, that is not a negative
+			// number of downloads.
+			name:   "decreasing",
+			counts: []int{10, 4, 6, -1, 7},
+			want: []statPoint{
+				{Date: "2020-01-01", Count: 10},
+				{Date: "2020-01-02", Count: 4},
+				{Date: "2020-01-03", Count: 6, Delta: 2},
+				{Date: "2020-01-04"},
+				{Date: "2020-01-05", Count: 7},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		if got := toPoints(dates, tc.counts); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s: got %+v, want %+v", tc.name, got, tc.want)
+		}
+	}
+}
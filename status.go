@@ -0,0 +1,179 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// refreshStatus holds the state of the refreshes for /status.
+type refreshStatus struct {
+	mu sync.Mutex
+
+	start       time.Time
+	end         time.Time
+	err         error
+	lastSuccess time.Time
+	// repoErrors holds the errors of the current or last refresh by
+	// repository.
+	repoErrors map[string]string
+}
+
+var refreshState = &refreshStatus{
+	repoErrors: map[string]string{},
+}
+
+// started marks the start of a refresh.
+func (s *refreshStatus) started(now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.start = now
+	s.end = time.Time{}
+	s.repoErrors = map[string]string{}
+}
+
+// finished marks the end of a refresh.
+func (s *refreshStatus) finished(now time.Time, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.end = now
+	s.err = err
+	if err == nil {
+		s.lastSuccess = now
+	}
+}
+
+// repoError records the error handling the repository.
+func (s *refreshStatus) repoError(repo string, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.repoErrors[repo] = err.Error()
+}
+
+// statusReport is served at /status.
+type statusReport struct {
+	Ready           bool              `json:"ready"`
+	Reason          string            `json:"reason,omitempty"`
+	Repositories    int               `json:"repositories"`
+	SnapshotUpdated *time.Time        `json:"snapshot_updated,omitempty"`
+	Interval        string            `json:"interval"`
+	Auth            string            `json:"auth"`
+	Refresh         refreshReport     `json:"refresh"`
+	LastSuccess     *time.Time        `json:"last_successful_refresh,omitempty"`
+	RepoErrors      map[string]string `json:"repository_errors"`
+	RateLimit       rateLimitReport   `json:"rate_limit"`
+	CacheDir        string            `json:"cache_dir"`
+	Cache           cacheStats        `json:"cache"`
+	StateDir        string            `json:"state_dir"`
+}
+
+type refreshReport struct {
+	Start    *time.Time `json:"start,omitempty"`
+	End      *time.Time `json:"end,omitempty"`
+	Duration string     `json:"duration,omitempty"`
+	Running  bool       `json:"running"`
+	Error    string     `json:"error,omitempty"`
+}
+
+type rateLimitReport struct {
+	Limit     int        `json:"limit"`
+	Remaining int        `json:"remaining"`
+	Reset     *time.Time `json:"reset,omitempty"`
+}
+
+// ready returns if the snapshot exists and is recent enough to serve, or
+// why not.
+func (s *snapshot) ready(now time.Time) (bool, string) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.releases == nil {
+		return false, "no release data yet"
+	}
+	configMu.RLock()
+	n, maxAge := readyIntervals, time.Duration(readyIntervals)*interval
+	configMu.RUnlock()
+	if n > 0 && now.Sub(s.updated) > maxAge {
+		return false, fmt.Sprintf("release data is %s old, more than %d times the interval", now.Sub(s.updated).Round(time.Second), n)
+	}
+	return true, ""
+}
+
+// healthHandler reports that the process is alive.
+func healthHandler(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "text/plain")
+	fmt.Fprintln(w, "ok")
+}
+
+// readyHandler reports if there is recent release data to serve.
+func (s *snapshot) readyHandler(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "text/plain")
+	if ok, reason := s.ready(time.Now()); !ok {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		fmt.Fprintln(w, reason)
+		return
+	}
+	fmt.Fprintln(w, "ok")
+}
+
+// statusHandler serves the state of the refreshes as JSON.
+func (s *snapshot) statusHandler(w http.ResponseWriter, req *http.Request) {
+	now := time.Now()
+
+	configMu.RLock()
+	r := statusReport{
+		Interval:   interval.String(),
+		Auth:       authMode(),
+		RepoErrors: map[string]string{},
+		CacheDir:   cacheDir,
+		StateDir:   stateDir,
+	}
+	configMu.RUnlock()
+	r.Cache = httpCache.stats()
+	r.Ready, r.Reason = s.ready(now)
+
+	s.mu.RLock()
+	r.Repositories = len(s.releases)
+	if !s.updated.IsZero() {
+		updated := s.updated
+		r.SnapshotUpdated = &updated
+	}
+	s.mu.RUnlock()
+
+	refreshState.mu.Lock()
+	if !refreshState.start.IsZero() {
+		start := refreshState.start
+		r.Refresh.Start = &start
+		r.Refresh.Running = refreshState.end.IsZero()
+		if !r.Refresh.Running {
+			end := refreshState.end
+			r.Refresh.End = &end
+			r.Refresh.Duration = end.Sub(start).String()
+		}
+	}
+	if refreshState.err != nil {
+		r.Refresh.Error = refreshState.err.Error()
+	}
+	if !refreshState.lastSuccess.IsZero() {
+		lastSuccess := refreshState.lastSuccess
+		r.LastSuccess = &lastSuccess
+	}
+	for repo, err := range refreshState.repoErrors {
+		r.RepoErrors[repo] = err
+	}
+	refreshState.mu.Unlock()
+
+	r.RateLimit.Limit = int(metricRateLimit.get())
+	r.RateLimit.Remaining = int(metricRateLimitRemaining.get())
+	if v := metricRateLimitReset.get(); v > 0 {
+		reset := time.Unix(int64(v), 0)
+		r.RateLimit.Reset = &reset
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.Encode(r)
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	htmltemplate "html/template"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+
+	units "github.com/docker/go-units"
+	"github.com/google/go-github/github"
+)
+
+const (
+	indexTemplateName       = "index.html"
+	repoTemplateName        = "repo.html"
+	releaseBodyTemplateName = "release-body.md"
+)
+
+// templates holds the parsed templates used for rendering.
+type templates struct {
+	index       *htmltemplate.Template
+	repo        *htmltemplate.Template
+	releaseBody *template.Template
+}
+
+// releaseBody is the data passed to the release body template.
+type releaseBody struct {
+	Repository *github.Repository
+	Release    *github.RepositoryRelease
+	// Platforms holds the binaries of the release keyed by os -> arch.
+	Platforms map[string]map[string]release
+	// Changelog holds the changes since the previous release if
+	// --changelog is set.
+	Changelog *changelog
+}
+
+// templateFuncs are the functions available to every template.
+var templateFuncs = map[string]interface{}{
+	"ToUpper":    strings.ToUpper,
+	"ToLower":    strings.ToLower,
+	"Title":      strings.Title,
+	"TrimPrefix": strings.TrimPrefix,
+	"TrimSuffix": strings.TrimSuffix,
+	"HasPrefix":  strings.HasPrefix,
+	"HasSuffix":  strings.HasSuffix,
+	"Contains":   strings.Contains,
+	"Replace": func(s, old, new string) string {
+		return strings.Replace(s, old, new, -1)
+	},
+	"Join": strings.Join,
+	"HumanSize": func(size int) string {
+		return units.HumanSize(float64(size))
+	},
+	"Since": func(t time.Time) string {
+		return units.HumanDuration(time.Since(t))
+	},
+	"Date": func(t time.Time) string {
+		return t.Format("2006-01-02")
+	},
+	"SRIHash": func(sha256sum string) string {
+		h, _ := sriHash(sha256sum)
+		return h
+	},
+}
+
+// loadTemplates parses the built-in templates, overriding each with the
+// file of the same name in dir if it exists.
+func loadTemplates(dir string) (*templates, error) {
+	var err error
+	t := &templates{}
+
+	s, err := readTemplate(dir, indexTemplateName, tmpl)
+	if err != nil {
+		return nil, err
+	}
+	t.index, err = htmltemplate.New(indexTemplateName).Funcs(templateFuncs).Parse(s)
+	if err != nil {
+		return nil, err
+	}
+
+	s, err = readTemplate(dir, repoTemplateName, repoTmpl)
+	if err != nil {
+		return nil, err
+	}
+	t.repo, err = htmltemplate.New(repoTemplateName).Funcs(templateFuncs).Parse(s)
+	if err != nil {
+		return nil, err
+	}
+
+	s, err = readTemplate(dir, releaseBodyTemplateName, releaseTmpl)
+	if err != nil {
+		return nil, err
+	}
+	t.releaseBody, err = template.New(releaseBodyTemplateName).Funcs(templateFuncs).Delims("<<", ">>").Parse(s)
+	if err != nil {
+		return nil, err
+	}
+
+	return t, nil
+}
+
+// readTemplate returns the contents of the template name in dir, or def if
+// dir is empty or does not contain it.
+func readTemplate(dir, name, def string) (string, error) {
+	if dir == "" {
+		return def, nil
+	}
+
+	b, err := ioutil.ReadFile(filepath.Join(dir, name))
+	if os.IsNotExist(err) {
+		return def, nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
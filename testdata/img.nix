@@ -0,0 +1,37 @@
+# Generated by https://github.com/genuinetools/releases, do not edit.
+{ lib, stdenv, fetchurl }:
+
+let
+  sources = {
+    x86_64-linux = fetchurl {
+      url = "https://github.com/genuinetools/img/releases/download/v0.5.11/img-linux-amd64";
+      hash = "sha256-haRvLlnVFWTUXEox0wmXRpTLImxhbyyFB6OEJYYXzHQ=";
+    };
+    aarch64-darwin = fetchurl {
+      url = "https://github.com/genuinetools/img/releases/download/v0.5.11/img-darwin-arm64";
+      hash = "sha256-ZH2U7DN0tskwYYoLhbvHKY+UVhJ6nuip/KfyjRvqi8E=";
+    };
+  };
+in
+stdenv.mkDerivation {
+  pname = "img";
+  version = "0.5.11";
+
+  src = sources.${stdenv.hostPlatform.system} or (throw "unsupported system: ${stdenv.hostPlatform.system}");
+
+  dontUnpack = true;
+
+  installPhase = ''
+    runHook preInstall
+    install -Dm755 $src $out/bin/img
+    runHook postInstall
+  '';
+
+  meta = {
+    description = "Builds \"images\" with \${HOME}'s config";
+    homepage = "https://github.com/genuinetools/img";
+    license = lib.getLicenseFromSpdxId "MIT";
+    platforms = [ "x86_64-linux" "aarch64-darwin" ];
+    mainProgram = "img";
+  };
+}
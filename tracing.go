@@ -0,0 +1,386 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/genuinetools/pkg/cli"
+	"github.com/gregjones/httpcache"
+	"github.com/sirupsen/logrus"
+)
+
+// The spans are exported as OTLP/HTTP JSON to a collector, or as a JSON line
+// per span to stderr.
+
+const (
+	spanKindInternal = 1
+	spanKindClient   = 3
+
+	// otlpBatchSize is how many spans are sent to the collector at once.
+	otlpBatchSize = 512
+	// otlpFlushInterval is how often the spans are sent to the collector.
+	otlpFlushInterval = 5 * time.Second
+)
+
+// tracer exports the spans, spans are not recorded if it is nil.
+var tracer *spanExporter
+
+type spanKey struct{}
+
+// span is a timed operation in a trace.
+type span struct {
+	traceID  [16]byte
+	id       [8]byte
+	parentID [8]byte
+	name     string
+	kind     int
+	start    time.Time
+	end      time.Time
+
+	mu    sync.Mutex
+	attrs []spanAttr
+	err   error
+}
+
+type spanAttr struct {
+	key   string
+	value interface{}
+}
+
+// attr returns a span attribute, value is a string, int, int64 or bool.
+func attr(key string, value interface{}) spanAttr {
+	return spanAttr{key: key, value: value}
+}
+
+// startSpan starts a span that is a child of the span in ctx, if any. The
+// returned span is nil if tracing is disabled, its methods are no-ops then.
+func startSpan(ctx context.Context, name string, kind int, attrs ...spanAttr) (context.Context, *span) {
+	if tracer == nil {
+		return ctx, nil
+	}
+
+	s := &span{
+		name:  name,
+		kind:  kind,
+		start: time.Now(),
+		attrs: attrs,
+	}
+	if parent, ok := ctx.Value(spanKey{}).(*span); ok {
+		s.traceID = parent.traceID
+		s.parentID = parent.id
+	} else {
+		rand.Read(s.traceID[:])
+	}
+	rand.Read(s.id[:])
+	return context.WithValue(ctx, spanKey{}, s), s
+}
+
+// set adds an attribute to the span.
+func (s *span) set(key string, value interface{}) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.attrs = append(s.attrs, attr(key, value))
+}
+
+// fail marks the span as failed with err, if it is not nil.
+func (s *span) fail(err error) {
+	if s == nil || err == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.err = err
+}
+
+// finish ends the span and exports it.
+func (s *span) finish() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	s.end = time.Now()
+	s.mu.Unlock()
+	tracer.export(s)
+}
+
+// spanExporter writes the finished spans to stderr, or batches them and sends
+// them to an OTLP/HTTP collector.
+type spanExporter struct {
+	endpoint string
+	w        io.Writer
+	client   *http.Client
+
+	mu    sync.Mutex
+	spans []*span
+
+	flush chan struct{}
+	done  chan struct{}
+	wg    sync.WaitGroup
+}
+
+// newSpanExporter returns an exporter for target, which is stderr or the url
+// of an OTLP/HTTP collector, like http://localhost:4318/v1/traces.
+func newSpanExporter(target string) (*spanExporter, error) {
+	// Spans are never written to stdout, since it has the output of the
+	// commands.
+	if target == "stdout" {
+		return nil, errors.New("--trace stdout is not supported since stdout has the output of the commands, use stderr")
+	}
+	if target == "stderr" {
+		return &spanExporter{w: os.Stderr}, nil
+	}
+	if !strings.HasPrefix(target, "http://") && !strings.HasPrefix(target, "https://") {
+		return nil, fmt.Errorf("--trace must be stderr or the url of an OTLP/HTTP collector, got %q", target)
+	}
+
+	e := &spanExporter{
+		endpoint: target,
+		// The requests to the collector use their own client, so they are
+		// not traced themselves.
+		client: &http.Client{Timeout: 10 * time.Second},
+		flush:  make(chan struct{}, 1),
+		done:   make(chan struct{}),
+	}
+	e.wg.Add(1)
+	go e.loop()
+	return e, nil
+}
+
+func (e *spanExporter) export(s *span) {
+	if e.w != nil {
+		b, err := json.Marshal(s.debugJSON())
+		if err != nil {
+			logrus.Warnf("encoding span %s failed: %v", s.name, err)
+			return
+		}
+		e.mu.Lock()
+		defer e.mu.Unlock()
+		e.w.Write(append(b, '\n'))
+		return
+	}
+
+	e.mu.Lock()
+	e.spans = append(e.spans, s)
+	full := len(e.spans) >= otlpBatchSize
+	e.mu.Unlock()
+	if full {
+		select {
+		case e.flush <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (e *spanExporter) loop() {
+	defer e.wg.Done()
+
+	ticker := time.NewTicker(otlpFlushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-e.done:
+			e.send()
+			return
+		case <-ticker.C:
+			e.send()
+		case <-e.flush:
+			e.send()
+		}
+	}
+}
+
+// send sends the pending spans to the collector, they are dropped if that
+// fails.
+func (e *spanExporter) send() {
+	e.mu.Lock()
+	spans := e.spans
+	e.spans = nil
+	e.mu.Unlock()
+
+	for len(spans) > 0 {
+		n := len(spans)
+		if n > otlpBatchSize {
+			n = otlpBatchSize
+		}
+		if err := e.post(spans[:n]); err != nil {
+			logrus.Warnf("sending %d spans to %s failed: %v", n, e.endpoint, err)
+		}
+		spans = spans[n:]
+	}
+}
+
+func (e *spanExporter) post(spans []*span) error {
+	b, err := json.Marshal(otlpRequest(spans))
+	if err != nil {
+		return err
+	}
+	resp, err := e.client.Post(e.endpoint, "application/json", bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("collector returned %s", resp.Status)
+	}
+	return nil
+}
+
+// flushSpans runs a command and sends the pending spans once it is done. It
+// is needed since the cli exits without running After if a command fails.
+type flushSpans struct {
+	cli.Command
+}
+
+func (c flushSpans) Run(ctx context.Context, args []string) error {
+	defer tracer.shutdown()
+	return c.Command.Run(ctx, args)
+}
+
+// shutdown sends the pending spans and stops the exporter.
+func (e *spanExporter) shutdown() {
+	if e == nil || e.done == nil {
+		return
+	}
+	close(e.done)
+	e.wg.Wait()
+}
+
+// debugJSON returns the span in a form that is easy to read when written to
+// stderr.
+func (s *span) debugJSON() map[string]interface{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	v := map[string]interface{}{
+		"trace_id": hex.EncodeToString(s.traceID[:]),
+		"span_id":  hex.EncodeToString(s.id[:]),
+		"name":     s.name,
+		"start":    s.start,
+		"duration": s.end.Sub(s.start).String(),
+	}
+	if s.parentID != ([8]byte{}) {
+		v["parent_span_id"] = hex.EncodeToString(s.parentID[:])
+	}
+	if len(s.attrs) > 0 {
+		attrs := map[string]interface{}{}
+		for _, a := range s.attrs {
+			attrs[a.key] = a.value
+		}
+		v["attributes"] = attrs
+	}
+	if s.err != nil {
+		v["error"] = s.err.Error()
+	}
+	return v
+}
+
+// otlpRequest returns the body of an OTLP/HTTP JSON export request for the
+// spans.
+func otlpRequest(spans []*span) map[string]interface{} {
+	out := make([]map[string]interface{}, 0, len(spans))
+	for _, s := range spans {
+		s.mu.Lock()
+		v := map[string]interface{}{
+			"traceId":           hex.EncodeToString(s.traceID[:]),
+			"spanId":            hex.EncodeToString(s.id[:]),
+			"name":              s.name,
+			"kind":              s.kind,
+			"startTimeUnixNano": strconv.FormatInt(s.start.UnixNano(), 10),
+			"endTimeUnixNano":   strconv.FormatInt(s.end.UnixNano(), 10),
+			"attributes":        otlpAttributes(s.attrs),
+		}
+		if s.parentID != ([8]byte{}) {
+			v["parentSpanId"] = hex.EncodeToString(s.parentID[:])
+		}
+		if s.err != nil {
+			// STATUS_CODE_ERROR
+			v["status"] = map[string]interface{}{"code": 2, "message": s.err.Error()}
+		}
+		s.mu.Unlock()
+		out = append(out, v)
+	}
+
+	return map[string]interface{}{
+		"resourceSpans": []interface{}{
+			map[string]interface{}{
+				"resource": map[string]interface{}{
+					"attributes": otlpAttributes([]spanAttr{attr("service.name", "releases")}),
+				},
+				"scopeSpans": []interface{}{
+					map[string]interface{}{
+						"scope": map[string]interface{}{"name": "releases"},
+						"spans": out,
+					},
+				},
+			},
+		},
+	}
+}
+
+func otlpAttributes(attrs []spanAttr) []interface{} {
+	out := make([]interface{}, 0, len(attrs))
+	for _, a := range attrs {
+		var v map[string]interface{}
+		switch x := a.value.(type) {
+		case bool:
+			v = map[string]interface{}{"boolValue": x}
+		case int:
+			// 64 bit integers are strings in OTLP JSON.
+			v = map[string]interface{}{"intValue": strconv.Itoa(x)}
+		case int64:
+			v = map[string]interface{}{"intValue": strconv.FormatInt(x, 10)}
+		default:
+			v = map[string]interface{}{"stringValue": fmt.Sprint(x)}
+		}
+		out = append(out, map[string]interface{}{"key": a.key, "value": v})
+	}
+	return out
+}
+
+// tracingTransport records a span for every GitHub API request.
+type tracingTransport struct {
+	base http.RoundTripper
+}
+
+func (t *tracingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// The query is left out of the url, since the asset redirects are signed
+	// with it.
+	u := *req.URL
+	u.RawQuery, u.Fragment, u.User = "", "", nil
+	_, s := startSpan(req.Context(), req.Method+" "+apiEndpoint(req.URL.Path), spanKindClient,
+		attr("http.method", req.Method),
+		attr("http.url", u.String()),
+	)
+	defer s.finish()
+
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		s.fail(err)
+		return resp, err
+	}
+	s.set("http.status_code", resp.StatusCode)
+	if resp.Header.Get(httpcache.XFromCache) != "" {
+		s.set("http.cache", "hit")
+	} else {
+		s.set("http.cache", "miss")
+	}
+	if resp.StatusCode >= 500 {
+		s.fail(fmt.Errorf("%s", resp.Status))
+	}
+	return resp, nil
+}
@@ -0,0 +1,259 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/go-github/github"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// updateQueueSize is the number of release body updates that can be
+	// waiting for a worker. Updates that do not fit are retried on the
+	// next refresh.
+	updateQueueSize = 256
+
+	updateStateFile = "release-bodies.json"
+)
+
+// bodyUpdate is a queued release body update.
+type bodyUpdate struct {
+	repo      *github.Repository
+	release   *github.RepositoryRelease
+	previous  *github.RepositoryRelease
+	platforms map[string]map[string]release
+	changelog string
+	dryRun    bool
+}
+
+// updateRecord is the persisted record of a release body we have written.
+type updateRecord struct {
+	Repository string    `json:"repository"`
+	Tag        string    `json:"tag"`
+	BodySHA256 string    `json:"body_sha256"`
+	Platforms  string    `json:"platforms"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// updateFailure is a release body update that failed.
+type updateFailure struct {
+	Repository string
+	Tag        string
+	Err        error
+	Time       time.Time
+}
+
+// bodyUpdater updates release bodies from a bounded queue with a fixed
+// number of workers.
+type bodyUpdater struct {
+	client *github.Client
+	queue  chan bodyUpdate
+	done   chan struct{}
+	wg     sync.WaitGroup
+
+	mu        sync.Mutex
+	queued    map[int64]bool
+	records   map[int64]updateRecord
+	failures  map[int64]updateFailure
+	updated   int
+	unchanged int
+	dropped   int
+}
+
+// newBodyUpdater creates a bodyUpdater and loads the record of the
+// release bodies that were already updated.
+func newBodyUpdater(client *github.Client) (*bodyUpdater, error) {
+	u := &bodyUpdater{
+		client:   client,
+		queue:    make(chan bodyUpdate, updateQueueSize),
+		done:     make(chan struct{}),
+		queued:   map[int64]bool{},
+		records:  map[int64]updateRecord{},
+		failures: map[int64]updateFailure{},
+	}
+
+	if err := loadState(updateStateFile, &u.records); err != nil {
+		return nil, fmt.Errorf("loading %s failed: %v", updateStateFile, err)
+	}
+
+	return u, nil
+}
+
+// start runs workers until ctx is cancelled or stop is called.
+func (u *bodyUpdater) start(ctx context.Context, workers int) {
+	for i := 0; i < workers; i++ {
+		u.wg.Add(1)
+		go func() {
+			defer u.wg.Done()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case <-u.done:
+					return
+				case bu := <-u.queue:
+					u.update(ctx, bu)
+				}
+			}
+		}()
+	}
+}
+
+// stop stops the workers once their current update is done and waits for
+// them. Updates still in the queue are dropped, they are queued again on the
+// next refresh after a restart.
+func (u *bodyUpdater) stop() {
+	close(u.done)
+	u.wg.Wait()
+}
+
+// setClient replaces the client used for the updates that are not started
+// yet, after a reload.
+func (u *bodyUpdater) setClient(client *github.Client) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.client = client
+}
+
+// enqueue queues the update unless it is already queued or the release
+// has not changed since we last updated it.
+func (u *bodyUpdater) enqueue(bu bodyUpdate) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	id := bu.release.GetID()
+	if u.queued[id] {
+		return
+	}
+	if rec, ok := u.records[id]; ok && rec.BodySHA256 == hashString(bu.release.GetBody()) && rec.Platforms == platformsFingerprint(bu.platforms) {
+		u.unchanged++
+		return
+	}
+
+	select {
+	case u.queue <- bu:
+		u.queued[id] = true
+	default:
+		u.dropped++
+		logrus.WithFields(logrus.Fields{
+			logFieldRepo: bu.repo.GetFullName(),
+			logFieldTag:  bu.release.GetTagName(),
+		}).Warn("Release body update queue is full, skipping it until the next refresh")
+	}
+}
+
+func (u *bodyUpdater) update(ctx context.Context, bu bodyUpdate) {
+	u.mu.Lock()
+	client := u.client
+	u.mu.Unlock()
+
+	ctx = withLogFields(ctx, logrus.Fields{
+		logFieldRepo: bu.repo.GetFullName(),
+		logFieldTag:  bu.release.GetTagName(),
+	})
+	metricBodyUpdates.inc()
+	r, updated, err := updateRelease(ctx, client, bu)
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	id := bu.release.GetID()
+	delete(u.queued, id)
+
+	if err != nil {
+		logFor(ctx).Warn(err)
+		metricBodyUpdatesFailed.inc()
+		u.failures[id] = updateFailure{
+			Repository: bu.repo.GetFullName(),
+			Tag:        bu.release.GetTagName(),
+			Err:        err,
+			Time:       time.Now(),
+		}
+		return
+	}
+	delete(u.failures, id)
+
+	if updated {
+		u.updated++
+	} else {
+		u.unchanged++
+	}
+
+	u.records[id] = updateRecord{
+		Repository: bu.repo.GetFullName(),
+		Tag:        bu.release.GetTagName(),
+		BodySHA256: hashString(r.GetBody()),
+		Platforms:  platformsFingerprint(bu.platforms),
+		UpdatedAt:  time.Now(),
+	}
+	if err := saveState(updateStateFile, u.records); err != nil {
+		logFor(ctx).Warnf("saving %s failed: %v", updateStateFile, err)
+	}
+}
+
+// String returns the progress of the release body updates.
+func (u *bodyUpdater) String() string {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "queued: %d\nupdated: %d\nunchanged: %d\nskipped (queue full): %d\nfailed: %d\n", len(u.queued), u.updated, u.unchanged, u.dropped, len(u.failures))
+
+	failures := make([]updateFailure, 0, len(u.failures))
+	for _, f := range u.failures {
+		failures = append(failures, f)
+	}
+	sort.Slice(failures, func(i, j int) bool {
+		return failures[i].Time.Before(failures[j].Time)
+	})
+	for _, f := range failures {
+		fmt.Fprintf(&b, "\n%s %s %s: %v", f.Time.Format(time.RFC3339), f.Repository, f.Tag, f.Err)
+	}
+	return b.String()
+}
+
+// shouldUpdateBody returns if the release body of the i-th newest release
+// should be updated according to --update-latest and --update-since, or the
+// overrides for the repository.
+func shouldUpdateBody(s repoSettings, i int, r *github.RepositoryRelease) bool {
+	if !s.update {
+		return false
+	}
+	if s.updateLatest > 0 && i >= s.updateLatest {
+		return false
+	}
+	if !s.updateSince.IsZero() && r.GetCreatedAt().Time.Before(s.updateSince) {
+		return false
+	}
+	return true
+}
+
+// platformsFingerprint returns a hash of the binaries and checksums of a
+// release, so we notice when assets are added after it was updated.
+func platformsFingerprint(platforms map[string]map[string]release) string {
+	var keys []string
+	for osn, v := range platforms {
+		for arch, r := range v {
+			keys = append(keys, osn+"/"+arch+" "+r.BinaryURL+" "+r.BinarySHA256)
+		}
+	}
+	sort.Strings(keys)
+
+	h := sha256.New()
+	for _, k := range keys {
+		fmt.Fprintln(h, k)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func hashString(s string) string {
+	h := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(h[:])
+}
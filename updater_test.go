@@ -0,0 +1,132 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-github/github"
+)
+
+func TestShouldUpdateBody(t *testing.T) {
+	since := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	created := func(d time.Time) *github.RepositoryRelease {
+		return &github.RepositoryRelease{CreatedAt: &github.Timestamp{Time: d}}
+	}
+
+	testCases := []struct {
+		name string
+		s    repoSettings
+		i    int
+		r    *github.RepositoryRelease
+		want bool
+	}{
+		{
+			name: "updates disabled",
+			s:    repoSettings{},
+			r:    created(since),
+		},
+		{
+			name: "no filters",
+			s:    repoSettings{update: true},
+			i:    100,
+			r:    created(since.AddDate(-10, 0, 0)),
+			want: true,
+		},
+		{
+			name: "within the latest",
+			s:    repoSettings{update: true, updateLatest: 2},
+			i:    1,
+			r:    created(since),
+			want: true,
+		},
+		{
+			name: "older than the latest",
+			s:    repoSettings{update: true, updateLatest: 2},
+			i:    2,
+			r:    created(since),
+		},
+		{
+			name: "created on the since date",
+			s:    repoSettings{update: true, updateSince: since},
+			r:    created(since),
+			want: true,
+		},
+		{
+			name: "created before the since date",
+			s:    repoSettings{update: true, updateSince: since},
+			r:    created(since.Add(-time.Second)),
+		},
+		{
+			name: "both filters must match",
+			s:    repoSettings{update: true, updateLatest: 5, updateSince: since},
+			i:    3,
+			r:    created(since.Add(-time.Hour)),
+		},
+	}
+
+	for _, tc := range testCases {
+		if got := shouldUpdateBody(tc.s, tc.i, tc.r); got != tc.want {
+			t.Errorf("%s: got %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestBodyUpdaterEnqueue(t *testing.T) {
+	prevStateDir := stateDir
+	stateDir = t.TempDir()
+	t.Cleanup(func() { stateDir = prevStateDir })
+
+	u, err := newBodyUpdater(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	u.queue = make(chan bodyUpdate, 2)
+
+	repo := &github.Repository{FullName: github.String("genuinetools/img")}
+	platforms := map[string]map[string]release{
+		"linux": {"amd64": {BinaryURL: "https://example.com/img-linux-amd64", BinarySHA256: "abc"}},
+	}
+	morePlatforms := map[string]map[string]release{
+		"linux": {
+			"amd64": {BinaryURL: "https://example.com/img-linux-amd64", BinarySHA256: "abc"},
+			"arm64": {BinaryURL: "https://example.com/img-linux-arm64", BinarySHA256: "def"},
+		},
+	}
+	// Release 1 was updated before, the record matches its body and
+	// binaries.
+	u.records[1] = updateRecord{BodySHA256: hashString("updated"), Platforms: platformsFingerprint(platforms)}
+	u.records[2] = updateRecord{BodySHA256: hashString("updated"), Platforms: platformsFingerprint(platforms)}
+
+	update := func(id int64, body string, platforms map[string]map[string]release) bodyUpdate {
+		return bodyUpdate{
+			repo:      repo,
+			release:   &github.RepositoryRelease{ID: github.Int64(id), Body: github.String(body)},
+			platforms: platforms,
+		}
+	}
+
+	steps := []struct {
+		name      string
+		bu        bodyUpdate
+		queued    int
+		unchanged int
+		dropped   int
+	}{
+		{"unchanged since the last update", update(1, "updated", platforms), 0, 1, 0},
+		{"body edited on GitHub", update(1, "edited", platforms), 1, 1, 0},
+		{"already queued", update(1, "edited again", platforms), 1, 1, 0},
+		{"binaries added", update(2, "updated", morePlatforms), 2, 1, 0},
+		{"queue full", update(3, "new", platforms), 2, 1, 1},
+	}
+
+	for _, step := range steps {
+		u.enqueue(step.bu)
+		if len(u.queued) != step.queued || u.unchanged != step.unchanged || u.dropped != step.dropped {
+			t.Errorf("%s: got %d queued, %d unchanged and %d dropped, want %d, %d and %d", step.name, len(u.queued), u.unchanged, u.dropped, step.queued, step.unchanged, step.dropped)
+		}
+	}
+
+	if got := (<-u.queue).release.GetBody(); got != "edited" {
+		t.Errorf("got the update for %q first, want the one for \"edited\"", got)
+	}
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+const (
+	wingetManifestVersion = "1.6.0"
+
+	wingetTmpl = `{{define "identity"}}PackageIdentifier: {{quote .Identifier}}
+PackageVersion: {{quote .Version}}
+{{end}}
+{{- define "locale"}}PackageLocale: en-US
+Publisher: {{quote .Publisher}}
+PackageName: {{quote .Name}}
+PackageUrl: {{quote .Homepage}}
+License: {{quote .License}}
+ShortDescription: {{quote .Description}}
+{{end}}
+{{- define "installer"}}InstallerType: portable
+Commands:
+  - {{quote .Name}}
+{{- if .ReleaseDate}}
+ReleaseDate: {{.ReleaseDate}}
+{{- end}}
+Installers:
+{{- range .Installers}}
+  - Architecture: {{.Architecture}}
+    InstallerUrl: {{quote .URL}}
+    InstallerSha256: {{.SHA256}}
+{{- end}}
+{{end}}
+{{- define "singleton"}}{{template "identity" .}}{{template "locale" .}}{{template "installer" .}}ManifestType: singleton
+ManifestVersion: {{.ManifestVersion}}
+{{end}}
+{{- define "version"}}{{template "identity" .}}DefaultLocale: en-US
+ManifestType: version
+ManifestVersion: {{.ManifestVersion}}
+{{end}}
+{{- define "defaultLocale"}}{{template "identity" .}}{{template "locale" .}}ManifestType: defaultLocale
+ManifestVersion: {{.ManifestVersion}}
+{{end}}
+{{- define "installers"}}{{template "identity" .}}{{template "installer" .}}ManifestType: installer
+ManifestVersion: {{.ManifestVersion}}
+{{end}}`
+)
+
+// wingetArchitectures maps GOARCH to the architecture names used by WinGet.
+var wingetArchitectures = map[string]string{
+	"amd64": "x64",
+	"386":   "x86",
+	"arm64": "arm64",
+}
+
+var wingetTemplate = template.Must(template.New("").Funcs(template.FuncMap{
+	// YAML double quoted scalars accept the same escapes as Go.
+	"quote": strconv.Quote,
+}).Parse(wingetTmpl))
+
+// wingetManifest holds the data for rendering the WinGet manifests.
+// See: https://github.com/microsoft/winget-pkgs/tree/master/doc/manifest
+type wingetManifest struct {
+	Identifier      string
+	Version         string
+	Publisher       string
+	Name            string
+	Homepage        string
+	License         string
+	Description     string
+	ReleaseDate     string
+	Installers      []wingetInstaller
+	ManifestVersion string
+}
+
+type wingetInstaller struct {
+	Architecture string
+	URL          string
+	SHA256       string
+}
+
+// newWingetManifest creates a WinGet manifest from the windows binaries of
+// the latest release.
+func newWingetManifest(r release) (*wingetManifest, error) {
+	if r.Release == nil {
+		return nil, fmt.Errorf("%s has no release", r.Repository.GetFullName())
+	}
+
+	m := &wingetManifest{
+		Identifier:      r.Repository.GetOwner().GetLogin() + "." + r.Repository.GetName(),
+		Version:         strings.TrimPrefix(r.Release.GetTagName(), "v"),
+		Publisher:       r.Repository.GetOwner().GetLogin(),
+		Name:            r.Repository.GetName(),
+		Homepage:        r.Repository.GetHTMLURL(),
+		License:         r.Repository.GetLicense().GetSPDXID(),
+		Description:     r.Repository.GetDescription(),
+		ManifestVersion: wingetManifestVersion,
+	}
+	if m.License == "" {
+		m.License = "NOASSERTION"
+	}
+	if m.Description == "" {
+		m.Description = m.Name
+	}
+	if r.Release.PublishedAt != nil {
+		m.ReleaseDate = r.Release.GetPublishedAt().Format("2006-01-02")
+	}
+
+	// Iterate in a stable order so the manifests do not change between
+	// refreshes.
+	for _, arch := range []string{"amd64", "386", "arm64"} {
+		b, ok := r.Platforms["windows"][arch]
+		if !ok || b.BinaryURL == "" || b.BinarySHA256 == "" {
+			continue
+		}
+
+		m.Installers = append(m.Installers, wingetInstaller{
+			Architecture: wingetArchitectures[arch],
+			URL:          b.BinaryURL,
+			SHA256:       strings.ToUpper(b.BinarySHA256),
+		})
+	}
+
+	if len(m.Installers) < 1 {
+		return nil, fmt.Errorf("%s %s has no windows binaries with checksums", r.Repository.GetFullName(), r.Release.GetTagName())
+	}
+
+	return m, nil
+}
+
+// files returns the multi-file manifests keyed by their path in the
+// winget-pkgs repository layout.
+func (m *wingetManifest) files() (map[string][]byte, error) {
+	dir := filepath.Join("manifests", strings.ToLower(m.Identifier[:1]), m.Publisher, m.Name, m.Version)
+
+	files := map[string][]byte{}
+	for name, suffix := range map[string]string{
+		"version":       ".yaml",
+		"defaultLocale": ".locale.en-US.yaml",
+		"installers":    ".installer.yaml",
+	} {
+		var b bytes.Buffer
+		if err := wingetTemplate.ExecuteTemplate(&b, name, m); err != nil {
+			return nil, err
+		}
+		files[filepath.Join(dir, m.Identifier+suffix)] = b.Bytes()
+	}
+
+	return files, nil
+}
+
+// wingetManifestBytes returns the singleton manifest for the release.
+func wingetManifestBytes(r release) ([]byte, error) {
+	m, err := newWingetManifest(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var b bytes.Buffer
+	if err := wingetTemplate.ExecuteTemplate(&b, "singleton", m); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}